@@ -0,0 +1,3 @@
+module github.com/example/webshop/cmd/loadgen
+
+go 1.22
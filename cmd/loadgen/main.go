@@ -0,0 +1,253 @@
+// loadgen — нагрузка на сценарий оформления заказа через gateway.
+// Создаёт пользователей, пополняет счета и шлёт заказы с заданным RPS,
+// меряя время от POST /orders до финального статуса заказа.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type options struct {
+	gateway     string
+	users       int
+	deposit     int64
+	amount      int64
+	rps         float64
+	duration    time.Duration
+	poll        time.Duration
+	timeout     time.Duration
+	maxInFlight int
+	prefix      string
+	jsonOut     string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.gateway, "gateway", "http://localhost:8080", "gateway base URL")
+	flag.IntVar(&opts.users, "users", 50, "number of users")
+	flag.Int64Var(&opts.deposit, "deposit", 1_000_000, "initial deposit per user")
+	flag.Int64Var(&opts.amount, "amount", 100, "order amount")
+	flag.Float64Var(&opts.rps, "rps", 20, "target orders per second")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to send orders")
+	flag.DurationVar(&opts.poll, "poll", 100*time.Millisecond, "order status poll interval")
+	flag.DurationVar(&opts.timeout, "timeout", 30*time.Second, "max wait for an order to leave NEW")
+	flag.IntVar(&opts.maxInFlight, "max-inflight", 1000, "max orders in flight, extra ticks are dropped")
+	flag.StringVar(&opts.prefix, "prefix", "", "user id prefix (default: loadgen-<unix time>)")
+	flag.StringVar(&opts.jsonOut, "json", "", "write JSON report to file (- for stdout)")
+	flag.Parse()
+
+	if opts.users <= 0 || opts.rps <= 0 || opts.duration <= 0 {
+		log.Fatal("users, rps and duration must be positive")
+	}
+	if opts.prefix == "" {
+		opts.prefix = fmt.Sprintf("loadgen-%d", time.Now().Unix())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &client{
+		base: opts.gateway,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConns:        opts.maxInFlight,
+				MaxIdleConnsPerHost: opts.maxInFlight,
+			},
+		},
+	}
+
+	users, err := setupUsers(ctx, c, opts)
+	if err != nil {
+		log.Fatalf("setup users: %v", err)
+	}
+	log.Printf("prepared %d users, sending %.1f orders/s for %s", len(users), opts.rps, opts.duration)
+
+	rec := newRecorder()
+	started := time.Now()
+	run(ctx, c, opts, users, rec)
+	rep := rec.report(opts, time.Since(started))
+
+	rep.writeText(os.Stdout)
+	if opts.jsonOut != "" {
+		if err := writeJSON(opts.jsonOut, rep); err != nil {
+			log.Fatalf("write json: %v", err)
+		}
+	}
+}
+
+func setupUsers(ctx context.Context, c *client, opts options) ([]string, error) {
+	users := make([]string, opts.users)
+	for i := range users {
+		userID := fmt.Sprintf("%s-%d", opts.prefix, i)
+		// 409 — счёт уже есть, это нормально при повторном прогоне с тем же префиксом
+		code, err := c.post(ctx, "/payments/accounts", map[string]any{"user_id": userID}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if code != http.StatusCreated && code != http.StatusConflict {
+			return nil, fmt.Errorf("create account %s: status %d", userID, code)
+		}
+		code, err = c.post(ctx, "/payments/accounts/deposit", map[string]any{"user_id": userID, "amount": opts.deposit}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if code != http.StatusOK {
+			return nil, fmt.Errorf("deposit %s: status %d", userID, code)
+		}
+		users[i] = userID
+	}
+	return users, nil
+}
+
+// run шлёт заказы по тикеру; каждый заказ дальше живёт в своей горутине до финального статуса
+func run(ctx context.Context, c *client, opts options, users []string, rec *recorder) {
+	interval := time.Duration(float64(time.Second) / opts.rps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(opts.duration)
+	defer deadline.Stop()
+
+	var wg sync.WaitGroup
+	var inFlight atomic.Int64
+	next := 0
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline.C:
+			break loop
+		case <-ticker.C:
+			if inFlight.Load() >= int64(opts.maxInFlight) {
+				rec.outcome(outcomeDropped)
+				continue
+			}
+			userID := users[next%len(users)]
+			next++
+			inFlight.Add(1)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer inFlight.Add(-1)
+				checkout(ctx, c, opts, userID, rec)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func checkout(ctx context.Context, c *client, opts options, userID string, rec *recorder) {
+	start := time.Now()
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	code, err := c.post(ctx, "/orders", map[string]any{"user_id": userID, "amount": opts.amount, "description": "loadgen"}, &created)
+	if err != nil || code != http.StatusOK {
+		rec.outcome(outcomeCreateError)
+		return
+	}
+	rec.create(time.Since(start))
+
+	waitUntil := start.Add(opts.timeout)
+	for {
+		if time.Now().After(waitUntil) {
+			rec.outcome(outcomeTimeout)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			rec.outcome(outcomeTimeout)
+			return
+		case <-time.After(opts.poll):
+		}
+
+		var order struct {
+			Status string `json:"status"`
+		}
+		code, err := c.get(ctx, fmt.Sprintf("/orders/%d", created.ID), &order)
+		if err != nil || code != http.StatusOK {
+			rec.outcome(outcomePollError)
+			return
+		}
+		switch order.Status {
+		case "NEW":
+			continue
+		case "FINISHED":
+			rec.finished(time.Since(start))
+			rec.outcome(outcomeFinished)
+		default:
+			rec.outcome(outcomeCancelled)
+		}
+		return
+	}
+}
+
+type client struct {
+	base string
+	http *http.Client
+}
+
+func (c *client) post(ctx context.Context, path string, body, out any) (int, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+path, bytes.NewReader(raw))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out)
+}
+
+func (c *client) get(ctx context.Context, path string, out any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	return c.do(req, out)
+}
+
+func (c *client) do(req *http.Request, out any) (int, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+func writeJSON(path string, rep report) error {
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	outcomeFinished    = "finished"
+	outcomeCancelled   = "cancelled"
+	outcomeCreateError = "create_error"
+	outcomePollError   = "poll_error"
+	outcomeTimeout     = "timeout"
+	outcomeDropped     = "dropped"
+)
+
+var outcomes = []string{outcomeFinished, outcomeCancelled, outcomeCreateError, outcomePollError, outcomeTimeout, outcomeDropped}
+
+// Границы корзин гистограммы, последняя корзина — всё, что дольше
+var bucketBounds = []time.Duration{
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+	5 * time.Second, 10 * time.Second,
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	counts   map[string]int
+	creates  []time.Duration
+	finishes []time.Duration
+}
+
+func newRecorder() *recorder {
+	return &recorder{counts: map[string]int{}}
+}
+
+func (r *recorder) outcome(name string) {
+	r.mu.Lock()
+	r.counts[name]++
+	r.mu.Unlock()
+}
+
+func (r *recorder) create(d time.Duration) {
+	r.mu.Lock()
+	r.creates = append(r.creates, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) finished(d time.Duration) {
+	r.mu.Lock()
+	r.finishes = append(r.finishes, d)
+	r.mu.Unlock()
+}
+
+type latency struct {
+	Count int     `json:"count"`
+	MinMs float64 `json:"min_ms"`
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+type bucket struct {
+	// LeMs — верхняя граница корзины, 0 у последней (+Inf)
+	LeMs  float64 `json:"le_ms"`
+	Count int     `json:"count"`
+}
+
+type report struct {
+	Gateway     string         `json:"gateway"`
+	Users       int            `json:"users"`
+	TargetRPS   float64        `json:"target_rps"`
+	ActualRPS   float64        `json:"actual_rps"`
+	DurationSec float64        `json:"duration_sec"`
+	Total       int            `json:"total"`
+	Outcomes    map[string]int `json:"outcomes"`
+	ErrorRate   float64        `json:"error_rate"`
+	CancelRate  float64        `json:"cancel_rate"`
+	Create      latency        `json:"create_latency"`
+	Checkout    latency        `json:"checkout_latency"`
+	Histogram   []bucket       `json:"checkout_histogram"`
+}
+
+func (r *recorder) report(opts options, elapsed time.Duration) report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := report{
+		Gateway:     opts.gateway,
+		Users:       opts.users,
+		TargetRPS:   opts.rps,
+		DurationSec: elapsed.Seconds(),
+		Outcomes:    map[string]int{},
+		Create:      summarize(r.creates),
+		Checkout:    summarize(r.finishes),
+		Histogram:   histogram(r.finishes),
+	}
+	for _, name := range outcomes {
+		rep.Outcomes[name] = r.counts[name]
+		rep.Total += r.counts[name]
+	}
+	if rep.Total > 0 {
+		errors := r.counts[outcomeCreateError] + r.counts[outcomePollError] + r.counts[outcomeTimeout] + r.counts[outcomeDropped]
+		rep.ErrorRate = float64(errors) / float64(rep.Total)
+		rep.CancelRate = float64(r.counts[outcomeCancelled]) / float64(rep.Total)
+	}
+	if elapsed > 0 {
+		sent := rep.Total - r.counts[outcomeDropped]
+		rep.ActualRPS = float64(sent) / elapsed.Seconds()
+	}
+	return rep
+}
+
+func summarize(samples []time.Duration) latency {
+	if len(samples) == 0 {
+		return latency{}
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	return latency{
+		Count: len(sorted),
+		MinMs: ms(sorted[0]),
+		AvgMs: ms(sum / time.Duration(len(sorted))),
+		P50Ms: ms(percentile(sorted, 50)),
+		P95Ms: ms(percentile(sorted, 95)),
+		P99Ms: ms(percentile(sorted, 99)),
+		MaxMs: ms(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile — nearest-rank по отсортированной выборке
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func histogram(samples []time.Duration) []bucket {
+	buckets := make([]bucket, len(bucketBounds)+1)
+	for i, b := range bucketBounds {
+		buckets[i].LeMs = ms(b)
+	}
+	for _, d := range samples {
+		i, _ := slices.BinarySearch(bucketBounds, d)
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+func ms(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
+
+func (rep report) writeText(w io.Writer) {
+	fmt.Fprintf(w, "gateway %s, %d users, target %.1f rps, actual %.1f rps over %.1fs\n",
+		rep.Gateway, rep.Users, rep.TargetRPS, rep.ActualRPS, rep.DurationSec)
+	fmt.Fprintf(w, "orders: %d total, error rate %.2f%%, cancel rate %.2f%%\n", rep.Total, rep.ErrorRate*100, rep.CancelRate*100)
+	for _, name := range outcomes {
+		fmt.Fprintf(w, "  %-13s %d\n", name, rep.Outcomes[name])
+	}
+	writeLatency(w, "POST /orders", rep.Create)
+	writeLatency(w, "order -> FINISHED", rep.Checkout)
+
+	fmt.Fprintln(w, "histogram (order -> FINISHED):")
+	peak := 0
+	for _, b := range rep.Histogram {
+		peak = max(peak, b.Count)
+	}
+	for _, b := range rep.Histogram {
+		label := "+Inf"
+		if b.LeMs > 0 {
+			label = fmt.Sprintf("%gms", b.LeMs)
+		}
+		bar := 0
+		if peak > 0 {
+			bar = b.Count * 40 / peak
+		}
+		line := fmt.Sprintf("  <= %-8s %7d %s", label, b.Count, strings.Repeat("#", bar))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
+
+func writeLatency(w io.Writer, title string, l latency) {
+	fmt.Fprintf(w, "%s (n=%d): min %.1fms avg %.1fms p50 %.1fms p95 %.1fms p99 %.1fms max %.1fms\n",
+		title, l.Count, l.MinMs, l.AvgMs, l.P50Ms, l.P95Ms, l.P99Ms, l.MaxMs)
+}
@@ -0,0 +1,188 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAdminBackOffice(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 1000)
+	id := createOrder(t, user, 400)
+	if status := waitStatus(t, id, settleTimeout); status != "FINISHED" {
+		t.Fatalf("order %d: status %s, want FINISHED", id, status)
+	}
+
+	resp, err := http.Get(env.gateway.URL + "/admin/orders?user_id=" + user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("admin without token: status %d, want 401", resp.StatusCode)
+	}
+
+	var found []struct {
+		ID     int64  `json:"id"`
+		Status string `json:"status"`
+	}
+	mustCall(t, http.MethodGet, "/admin/orders?status=finished&user_id="+user, nil, http.StatusOK, &found)
+	if len(found) != 1 || found[0].ID != id {
+		t.Fatalf("search: %+v, want order %d", found, id)
+	}
+
+	var view struct {
+		OrdersOutbox []map[string]any `json:"orders_outbox"`
+		Payment      *struct {
+			Status string `json:"status"`
+		} `json:"payment"`
+		PaymentsInbox []map[string]any  `json:"payments_inbox"`
+		Errors        map[string]string `json:"errors"`
+	}
+	mustCall(t, http.MethodGet, fmt.Sprintf("/admin/orders/%d", id), nil, http.StatusOK, &view)
+	if view.Payment == nil || view.Payment.Status != "FINISHED" || len(view.Errors) > 0 {
+		t.Fatalf("order view: payment %+v, errors %v", view.Payment, view.Errors)
+	}
+	if len(view.OrdersOutbox) != 1 || len(view.PaymentsInbox) != 1 {
+		t.Fatalf("order view: %d outbox rows, %d inbox rows, want 1 and 1", len(view.OrdersOutbox), len(view.PaymentsInbox))
+	}
+
+	// Отмена вручную и возврат денег корректировкой
+	mustCall(t, http.MethodPost, fmt.Sprintf("/admin/orders/%d/status", id),
+		map[string]any{"status": "CANCELLED", "reason": "customer complaint"}, http.StatusOK, nil)
+	if status := orderStatus(t, id); status != "CANCELLED" {
+		t.Fatalf("order %d: status %s after force, want CANCELLED", id, status)
+	}
+	var adj struct {
+		BalanceAfter int64  `json:"balance_after"`
+		OperatorID   string `json:"operator_id"`
+	}
+	mustCall(t, http.MethodPost, "/admin/accounts/"+user+"/adjustments",
+		map[string]any{"amount": 400, "reason": "refund for cancelled order"}, http.StatusCreated, &adj)
+	if adj.BalanceAfter != 1000 || adj.OperatorID != adminOperator {
+		t.Fatalf("adjustment: %+v, want balance 1000 by %s", adj, adminOperator)
+	}
+	mustCall(t, http.MethodPost, "/admin/accounts/"+user+"/adjustments",
+		map[string]any{"amount": -5000, "reason": "too much"}, http.StatusConflict, nil)
+
+	var accounts []struct {
+		UserID  string `json:"user_id"`
+		Balance int64  `json:"balance"`
+	}
+	mustCall(t, http.MethodGet, "/admin/accounts?min_balance=1000&max_balance=1000&limit=500", nil, http.StatusOK, &accounts)
+	for _, a := range accounts {
+		if a.UserID == user {
+			return
+		}
+	}
+	t.Fatalf("account %s not listed among balances of 1000", user)
+}
+
+func TestAdminRefund(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 1000)
+	id := createOrder(t, user, 400)
+	if status := waitStatus(t, id, settleTimeout); status != "FINISHED" {
+		t.Fatalf("order %d: status %s, want FINISHED", id, status)
+	}
+
+	var p struct {
+		Status string `json:"status"`
+		Amount int64  `json:"amount"`
+	}
+	mustCall(t, http.MethodPost, fmt.Sprintf("/admin/orders/%d/refund", id),
+		map[string]any{"reason": "item never arrived"}, http.StatusOK, &p)
+	if p.Status != "REFUNDED" || p.Amount != 400 {
+		t.Fatalf("refund: %+v, want REFUNDED 400", p)
+	}
+	if got := balance(t, user); got != 1000 {
+		t.Fatalf("balance after refund: %d, want 1000", got)
+	}
+	mustCall(t, http.MethodPost, fmt.Sprintf("/admin/orders/%d/refund", id),
+		map[string]any{"reason": "again"}, http.StatusConflict, nil)
+
+	// Заказ узнаёт о возврате из payments.refunded
+	deadline := time.Now().Add(settleTimeout)
+	for orderStatus(t, id) != "REFUNDED" {
+		if time.Now().After(deadline) {
+			t.Fatalf("order %d not REFUNDED after %s", id, settleTimeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	var adjustments []struct {
+		Amount     int64  `json:"amount"`
+		OperatorID string `json:"operator_id"`
+	}
+	mustCall(t, http.MethodGet, "/admin/accounts/"+user+"/adjustments", nil, http.StatusOK, &adjustments)
+	if len(adjustments) != 1 || adjustments[0].Amount != 400 || adjustments[0].OperatorID != adminOperator {
+		t.Fatalf("adjustments: %+v, want one +400 by %s", adjustments, adminOperator)
+	}
+}
+
+type deadLetter struct {
+	ID         int64      `json:"id"`
+	MessageID  string     `json:"message_id"`
+	Error      string     `json:"error"`
+	RedrivenAt *time.Time `json:"redriven_at"`
+	RedrivenBy string     `json:"redriven_by"`
+}
+
+// waitDeadLetter ждёт ещё не отправленную заново запись с этим message_id
+func waitDeadLetter(t *testing.T, messageID string) deadLetter {
+	t.Helper()
+	deadline := time.Now().Add(settleTimeout)
+	for {
+		var letters []deadLetter
+		mustCall(t, http.MethodGet, "/payments/admin/replay/dead-letters", nil, http.StatusOK, &letters)
+		for _, l := range letters {
+			if l.MessageID == messageID {
+				return l
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message %s not dead-lettered after %s", messageID, settleTimeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func TestDeadLetterRedrive(t *testing.T) {
+	// Версии 9 консьюмер не знает — такое сообщение уходит в dead_letters, а не теряется
+	messageID := uuid.NewString()
+	line := fmt.Sprintf(`{"id":%q,"type":"orders.created","version":9,"timestamp":"2024-05-01T00:00:00Z","producer":"e2e","data":{}}`, messageID)
+	mustCall(t, http.MethodPost, "/payments/admin/replay/inject?queue=order.payments", strings.NewReader(line), http.StatusOK, nil)
+
+	first := waitDeadLetter(t, messageID)
+	if !strings.Contains(first.Error, "orders.created.v9") {
+		t.Fatalf("dead letter error %q, want unsupported orders.created.v9", first.Error)
+	}
+	var backlog struct {
+		DeadLetters struct {
+			Pending int64 `json:"pending"`
+		} `json:"dead_letters"`
+	}
+	mustCall(t, http.MethodGet, "/payments/admin/replay/backlog", nil, http.StatusOK, &backlog)
+	if backlog.DeadLetters.Pending < 1 {
+		t.Fatalf("backlog: %d pending dead letters, want at least 1", backlog.DeadLetters.Pending)
+	}
+
+	var redriven deadLetter
+	mustCall(t, http.MethodPost, fmt.Sprintf("/payments/admin/replay/dead-letters/%d/redrive", first.ID), nil, http.StatusOK, &redriven)
+	if redriven.RedrivenAt == nil || redriven.RedrivenBy != adminOperator {
+		t.Fatalf("redrive: %+v, want redriven by %s", redriven, adminOperator)
+	}
+	mustCall(t, http.MethodPost, fmt.Sprintf("/payments/admin/replay/dead-letters/%d/redrive", first.ID), nil, http.StatusConflict, nil)
+
+	// Консьюмер так и не умеет v9 — сообщение вернулось в очередь и легло новой записью
+	if again := waitDeadLetter(t, messageID); again.ID == first.ID {
+		t.Fatalf("redriven message was not consumed again")
+	}
+}
@@ -0,0 +1,191 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const settleTimeout = 30 * time.Second
+
+func TestCheckoutFinished(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 2000)
+
+	id := createOrder(t, user, 1500)
+	if status := waitStatus(t, id, settleTimeout); status != "FINISHED" {
+		t.Fatalf("order %d: status %s, want FINISHED", id, status)
+	}
+	if got := balance(t, user); got != 500 {
+		t.Fatalf("balance %d, want 500", got)
+	}
+}
+
+func TestCheckoutInsufficientFunds(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 100)
+
+	id := createOrder(t, user, 500)
+	if status := waitStatus(t, id, settleTimeout); status != "CANCELLED" {
+		t.Fatalf("order %d: status %s, want CANCELLED", id, status)
+	}
+	if got := balance(t, user); got != 100 {
+		t.Fatalf("balance %d, want 100", got)
+	}
+	// Причина отказа доезжает до заказа — её показывает история заказов на фронте
+	var o struct {
+		FailureCode   string `json:"failure_code"`
+		FailureReason string `json:"failure_reason"`
+	}
+	mustCall(t, http.MethodGet, fmt.Sprintf("/orders/%d", id), nil, http.StatusOK, &o)
+	if o.FailureCode != "INSUFFICIENT_FUNDS" || o.FailureReason == "" {
+		t.Fatalf("order %d: failure %q / %q, want INSUFFICIENT_FUNDS with a reason", id, o.FailureCode, o.FailureReason)
+	}
+}
+
+// История заказов постранично: новые сначала, общее число в X-Total-Count
+func TestOrderHistoryPage(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 0)
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		ids = append(ids, createOrder(t, user, 100))
+	}
+
+	resp, err := http.Get(env.gateway.URL + "/orders?user_id=" + user + "&limit=2&offset=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Total-Count"); got != "3" {
+		t.Fatalf("X-Total-Count %q, want 3", got)
+	}
+	var page []struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[0] {
+		t.Fatalf("page %+v, want orders %d, %d", page, ids[1], ids[0])
+	}
+
+	code, body := call(t, http.MethodGet, "/orders?user_id="+user+"&limit=0", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("limit=0: status %d, want 400: %s", code, body)
+	}
+}
+
+func TestCheckoutFrozenAccount(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 1000)
+	mustCall(t, http.MethodPost, "/payments/admin/accounts/"+user+"/freeze",
+		map[string]any{"reason": "e2e"}, http.StatusOK, nil)
+
+	id := createOrder(t, user, 300)
+	if status := waitStatus(t, id, settleTimeout); status != "CANCELLED" {
+		t.Fatalf("order %d: status %s, want CANCELLED", id, status)
+	}
+	if got := balance(t, user); got != 1000 {
+		t.Fatalf("balance %d, want 1000", got)
+	}
+}
+
+// Повторная доставка orders.created не должна списать деньги второй раз,
+// а повтор payments.* — поменять статус заказа.
+func TestDuplicateDelivery(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 1000)
+
+	id := createOrder(t, user, 300)
+	if status := waitStatus(t, id, settleTimeout); status != "FINISHED" {
+		t.Fatalf("order %d: status %s, want FINISHED", id, status)
+	}
+
+	created := mustCall(t, http.MethodGet, fmt.Sprintf("/orders/admin/replay/outbox/export?order_id=%d", id), nil, http.StatusOK, nil)
+	if len(bytes.TrimSpace(created)) == 0 {
+		t.Fatalf("order %d: empty outbox export", id)
+	}
+	// одно и то же сообщение дважды напрямую в очередь плюс переопубликация из outbox
+	mustCall(t, http.MethodPost, "/orders/admin/replay/inject?queue=order.payments",
+		bytes.NewReader(append(append([]byte{}, created...), created...)), http.StatusOK, nil)
+	mustCall(t, http.MethodPost, "/orders/admin/replay/outbox/requeue",
+		map[string]any{"order_id": id}, http.StatusOK, nil)
+
+	result := mustCall(t, http.MethodGet, fmt.Sprintf("/payments/admin/replay/outbox/export?order_id=%d", id), nil, http.StatusOK, nil)
+	mustCall(t, http.MethodPost, "/payments/admin/replay/inject?queue=payment.status",
+		bytes.NewReader(result), http.StatusOK, nil)
+
+	// Сообщения одного пользователя обрабатываются по порядку,
+	// так что когда дошёл следующий заказ, дубли уже разобраны.
+	sentinel := createOrder(t, user, 100)
+	if status := waitStatus(t, sentinel, settleTimeout); status != "FINISHED" {
+		t.Fatalf("sentinel order %d: status %s, want FINISHED", sentinel, status)
+	}
+	if got := balance(t, user); got != 600 {
+		t.Fatalf("balance %d, want 600 (charged once per order)", got)
+	}
+	if status := orderStatus(t, id); status != "FINISHED" {
+		t.Fatalf("order %d: status %s after replay, want FINISHED", id, status)
+	}
+}
+
+// Пока брокер лежит, заказ копится в outbox и доезжает после его возвращения
+func TestBrokerRestart(t *testing.T) {
+	user := newUser(t)
+	createAccount(t, user, 1000)
+
+	if err := env.rabbit.stop(); err != nil {
+		t.Fatal(err)
+	}
+	stopped := true
+	t.Cleanup(func() {
+		if stopped {
+			_ = env.rabbit.start()
+		}
+	})
+
+	id := createOrder(t, user, 400)
+	time.Sleep(2 * time.Second)
+	if status := orderStatus(t, id); status != "NEW" {
+		t.Fatalf("order %d: status %s while broker is down, want NEW", id, status)
+	}
+
+	if err := env.rabbit.start(); err != nil {
+		t.Fatal(err)
+	}
+	stopped = false
+	if err := env.waitRabbit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := waitStatus(t, id, 60*time.Second); status != "FINISHED" {
+		t.Fatalf("order %d: status %s, want FINISHED", id, status)
+	}
+	if got := balance(t, user); got != 600 {
+		t.Fatalf("balance %d, want 600", got)
+	}
+
+	// и ещё раз — обычный рестарт посреди работы
+	if _, err := docker("restart", env.rabbit.id); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.waitRabbit(); err != nil {
+		t.Fatal(err)
+	}
+	id = createOrder(t, user, 100)
+	if status := waitStatus(t, id, 60*time.Second); status != "FINISHED" {
+		t.Fatalf("order %d: status %s, want FINISHED", id, status)
+	}
+	if got := balance(t, user); got != 500 {
+		t.Fatalf("balance %d, want 500", got)
+	}
+}
@@ -0,0 +1,5 @@
+// Package e2e — сквозные тесты: оба сервиса и gateway поднимаются в процессе теста
+// поверх одноразовых Postgres и RabbitMQ в docker.
+//
+// Запуск: go test -tags e2e ./e2e/...
+package e2e
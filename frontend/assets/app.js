@@ -0,0 +1,370 @@
+// Витрина покупателя. Роутинг на History API: фронт отдаёт index.html только на / и на префиксы из SPA_ROUTES
+// (/history,/backoffice), поэтому /history и /history/42 открываются и по прямой ссылке, и после F5.
+// Новый клиентский маршрут надо дописать в SPA_ROUTES, иначе прямая ссылка на него получит 404.
+// Свои пути не должны пересекаться с API gateway: /orders, /payments, /admin, /api.
+'use strict';
+
+const USER_KEY = 'shop.user';
+const PAGE_SIZE = 10;
+const POLL_MS = 1500;
+const USER_PATTERN = '[A-Za-z0-9._@-]{1,64}';
+const MAX_AMOUNT = 1000000000;
+
+// reason_code из payments → что показать покупателю; неизвестный код — текст причины как есть
+const FAILURE_TEXT = {
+  INSUFFICIENT_FUNDS: 'Not enough money on the balance. Top up and place the order again.',
+  ACCOUNT_NOT_FOUND: 'There is no payment account for this user yet.',
+  ACCOUNT_FROZEN: 'The account is frozen, please contact support.',
+  ACCOUNT_CLOSED: 'The account is closed.',
+  LIMIT_SINGLE_PAYMENT: 'The order is above the single payment limit.',
+  LIMIT_DAILY_SPEND: 'The daily spending limit is reached.',
+  LIMIT_MONTHLY_SPEND: 'The monthly spending limit is reached.',
+  LIMIT_ORDERS_PER_HOUR: 'Too many orders in the last hour, try again later.',
+};
+
+// Конфиг окружения из /config.js (frontend собирает его из env). Не загрузился — API на том же origin, всё включено
+const CONFIG = Object.assign({ apiBase: '', environment: '', version: '', features: {} }, window.APP_CONFIG);
+
+const view = document.getElementById('view');
+const flashEl = document.getElementById('flash');
+const balanceEl = document.getElementById('balance');
+let timer = null;
+let flashTimer = null;
+let lastBalance = null;
+
+function feature(name) {
+  return CONFIG.features[name] !== false;
+}
+
+function user() {
+  return localStorage.getItem(USER_KEY) || '';
+}
+
+// request возвращает JSON и заголовки; ошибка — Error с detail и violations из problem+json
+async function request(method, path, body) {
+  const headers = {};
+  if (body !== undefined) headers['Content-Type'] = 'application/json';
+  const res = await fetch(CONFIG.apiBase + path, { method, headers, body: body === undefined ? undefined : JSON.stringify(body) });
+  const text = await res.text();
+  let data = null;
+  try { data = text ? JSON.parse(text) : null; } catch (e) { data = null; }
+  if (!res.ok) {
+    const err = new Error(data && data.detail ? data.detail : `HTTP ${res.status}`);
+    err.status = res.status;
+    err.code = data ? data.code : '';
+    err.violations = (data && data.violations) || [];
+    throw err;
+  }
+  return { data, headers: res.headers };
+}
+
+async function api(method, path, body) {
+  return (await request(method, path, body)).data;
+}
+
+function h(tag, attrs, ...children) {
+  const el = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k.startsWith('on')) el.addEventListener(k.slice(2), v);
+    else if (v === true) el.setAttribute(k, '');
+    else if (v !== undefined && v !== null && v !== false) el.setAttribute(k, v);
+  }
+  for (const c of children.flat()) {
+    if (c === undefined || c === null || c === false) continue;
+    el.append(c instanceof Node ? c : String(c));
+  }
+  return el;
+}
+
+function link(href, ...children) {
+  return h('a', { href, 'data-link': true }, ...children);
+}
+
+function table(columns, rows, onClick) {
+  return h('table', {},
+    h('thead', {}, h('tr', {}, columns.map(([title]) => h('th', {}, title)))),
+    h('tbody', {}, rows.map(row => h('tr', onClick ? { class: 'link', onclick: () => onClick(row) } : {},
+      columns.map(([, cell]) => h('td', {}, cell(row)))))));
+}
+
+function kv(pairs) {
+  return h('div', { class: 'kv' }, pairs.flatMap(([k, v]) => [h('span', { class: 'muted' }, k), h('span', {}, v)]));
+}
+
+function badge(status) {
+  return h('span', { class: `status ${status}` }, status);
+}
+
+function when(t) {
+  return t ? new Date(t).toLocaleString() : '—';
+}
+
+function money(n) {
+  return Number(n).toLocaleString();
+}
+
+function failureText(o) {
+  if (o.status !== 'CANCELLED') return '';
+  return FAILURE_TEXT[o.failure_code] || o.failure_reason || 'Payment was declined.';
+}
+
+function flash(message, kind, details) {
+  clearTimeout(flashTimer);
+  flashEl.replaceChildren(message);
+  if (details && details.length) flashEl.append(h('ul', {}, details.map(d => h('li', {}, d))));
+  flashEl.className = kind;
+  flashEl.hidden = false;
+  flashTimer = setTimeout(() => { flashEl.hidden = true; }, 6000);
+}
+
+// Ошибку валидации gateway (violations) показываем по полям, остальные — одной строкой
+function showError(err) {
+  flash(err.message, 'error', err.violations.map(v => `${v.field || v.in}: ${v.message}`));
+}
+
+// amountField — общая проверка сумм: браузерной min/step мало, "1e3" и пустая строка проходят в Number()
+function amountField(form, name) {
+  const input = form.elements[name];
+  const value = input.value.trim();
+  let msg = '';
+  if (!/^[0-9]+$/.test(value)) msg = 'Enter a whole number';
+  else if (Number(value) < 1) msg = 'Amount must be at least 1';
+  else if (Number(value) > MAX_AMOUNT) msg = `Amount must be at most ${money(MAX_AMOUNT)}`;
+  input.setCustomValidity(msg);
+  return msg ? null : Number(value);
+}
+
+// submit: проверка формы, запрос и сообщение; кнопка заблокирована, пока запрос в полёте
+function onSubmit(handler) {
+  return async e => {
+    e.preventDefault();
+    const form = e.target;
+    const button = form.querySelector('button[type=submit]');
+    try {
+      button.disabled = true;
+      await handler(form);
+    } catch (err) {
+      showError(err);
+    } finally {
+      button.disabled = false;
+    }
+  };
+}
+
+function validate(form) {
+  if (form.reportValidity()) return true;
+  for (const input of form.elements) input.addEventListener('input', () => input.setCustomValidity(''), { once: true });
+  return false;
+}
+
+async function refreshBalance() {
+  if (!user()) return;
+  try {
+    const b = await api('GET', `/payments/accounts/${encodeURIComponent(user())}/balance`);
+    balanceEl.textContent = b.status === 'ACTIVE' ? money(b.balance) : `${money(b.balance)} · ${b.status}`;
+    // Подсвечиваем, когда баланс изменился (списание после оплаты, пополнение)
+    balanceEl.classList.toggle('changed', lastBalance !== null && lastBalance !== b.balance);
+    lastBalance = b.balance;
+  } catch (err) {
+    balanceEl.textContent = err.code === 'account_not_found' ? 'No account' : '—';
+    lastBalance = null;
+  }
+}
+
+// Вход — просто запомнить user_id: авторизации у покупателей нет, как и в API
+function signInPage() {
+  const form = h('form', { onsubmit: onSubmit(async f => {
+    if (!validate(f)) return;
+    const id = f.elements.user_id.value.trim();
+    if (f.elements.create.checked) {
+      try {
+        await api('POST', '/payments/accounts', { user_id: id });
+      } catch (err) {
+        if (err.code !== 'account_exists') throw err;
+      }
+    }
+    localStorage.setItem(USER_KEY, id);
+    lastBalance = null;
+    renderSession();
+    route();
+  }) },
+    h('div', { class: 'row' }, h('label', { for: 'user_id' }, 'User ID'),
+      h('input', { id: 'user_id', name: 'user_id', required: true, pattern: USER_PATTERN, placeholder: 'user-123',
+        title: 'Letters, digits and . _ @ -, up to 64 characters', autocomplete: 'username' })),
+    h('div', { class: 'row' }, h('label', {}, ''),
+      h('label', { class: 'inline' }, h('input', { name: 'create', type: 'checkbox' }), ' Create a payment account')),
+    h('button', { type: 'submit' }, 'Sign in'));
+  view.replaceChildren(h('section', {}, h('h2', {}, 'Sign in'), form));
+}
+
+function shopPage() {
+  const deposit = h('form', { onsubmit: onSubmit(async f => {
+    const amount = amountField(f, 'amount');
+    if (!validate(f)) return;
+    await api('POST', '/payments/accounts/deposit', { user_id: user(), amount });
+    flash(`Deposited ${money(amount)}`, 'ok');
+    f.reset();
+    refreshBalance();
+  }) },
+    h('div', { class: 'row' }, h('label', { for: 'deposit' }, 'Amount'),
+      h('input', { id: 'deposit', name: 'amount', type: 'number', min: 1, max: MAX_AMOUNT, step: 1, required: true, placeholder: '1000' })),
+    h('button', { type: 'submit' }, 'Top up'));
+
+  const order = h('form', { onsubmit: onSubmit(async f => {
+    const amount = amountField(f, 'amount');
+    if (!validate(f)) return;
+    const o = await api('POST', '/orders', { user_id: user(), amount, description: f.elements.description.value.trim() });
+    flash(`Order #${o.id} placed, waiting for payment`, 'ok');
+    navigate(`/history/${o.id}`);
+  }) },
+    h('div', { class: 'row' }, h('label', { for: 'amount' }, 'Amount'),
+      h('input', { id: 'amount', name: 'amount', type: 'number', min: 1, max: MAX_AMOUNT, step: 1, required: true, placeholder: '500' })),
+    h('div', { class: 'row' }, h('label', { for: 'description' }, 'Description'),
+      h('input', { id: 'description', name: 'description', maxlength: 200, placeholder: 'Gift' })),
+    h('button', { type: 'submit' }, 'Place order'));
+
+  view.replaceChildren(
+    h('section', {}, h('h2', {}, 'New order'), order),
+    feature('deposit') && h('section', {}, h('h2', {}, 'Top up balance'), deposit),
+    h('p', {}, link('/history', 'Order history →')));
+  refreshBalance();
+}
+
+async function historyPage() {
+  const page = Math.max(1, parseInt(new URLSearchParams(location.search).get('page'), 10) || 1);
+  const q = new URLSearchParams({ user_id: user(), limit: PAGE_SIZE, offset: (page - 1) * PAGE_SIZE });
+  const { data, headers } = await request('GET', `/orders?${q}`);
+  const total = Number(headers.get('X-Total-Count')) || 0;
+  const pages = Math.max(1, Math.ceil(total / PAGE_SIZE));
+
+  const body = data.length === 0
+    ? h('p', { class: 'muted' }, page > 1 ? 'No orders on this page.' : 'No orders yet. ', page > 1 ? '' : link('/', 'Place the first one'))
+    : table([
+      ['#', o => link(`/history/${o.id}`, `#${o.id}`)],
+      ['Date', o => when(o.created_at)],
+      ['Description', o => o.description],
+      ['Amount', o => money(o.amount)],
+      ['Status', o => badge(o.status)],
+      ['', o => h('span', { class: 'reason' }, failureText(o))],
+    ], data, o => navigate(`/history/${o.id}`));
+
+  view.replaceChildren(h('section', {}, h('h2', {}, `My orders (${total})`), body,
+    h('div', { class: 'pager' },
+      page > 1 ? link(`/history?page=${page - 1}`, '← Newer') : h('span', { class: 'muted' }, '← Newer'),
+      h('span', {}, `Page ${page} of ${pages}`),
+      page < pages ? link(`/history?page=${page + 1}`, 'Older →') : h('span', { class: 'muted' }, 'Older →'))));
+
+  // Пока есть неоплаченные заказы на странице — обновляем её
+  if (data.some(o => o.status === 'NEW')) timer = setTimeout(() => historyPage().catch(showError), POLL_MS);
+}
+
+// orderPage опрашивает заказ, пока он NEW; как только оплата прошла или отменена — обновляет баланс
+async function orderPage(id, settled) {
+  let o;
+  try {
+    o = await api('GET', `/orders/${encodeURIComponent(id)}`);
+  } catch (err) {
+    if (err.status !== 404 && err.status !== 400) throw err;
+    o = null;
+  }
+  // Чужой заказ показываем как несуществующий
+  if (!o || o.user_id !== user()) {
+    view.replaceChildren(h('section', {}, h('h2', {}, `Order #${id}`), h('p', { class: 'muted' }, 'Order not found. '),
+      link('/history', '← Back to orders')));
+    return;
+  }
+
+  const pending = o.status === 'NEW';
+  view.replaceChildren(h('section', {},
+    h('h2', {}, `Order #${o.id} `, badge(o.status)),
+    kv([
+      ['Description', o.description || '—'],
+      ['Amount', money(o.amount)],
+      ['Placed', when(o.created_at)],
+      ['Payment', pending ? 'Waiting for payment…' : paymentText(o)],
+    ]),
+    h('p', {}, link('/history', '← Back to orders'))));
+
+  if (pending) {
+    timer = setTimeout(() => orderPage(id, true).catch(showError), POLL_MS);
+  } else if (settled) {
+    refreshBalance();
+  }
+}
+
+function paymentText(o) {
+  switch (o.status) {
+    case 'FINISHED': return 'Paid';
+    case 'REFUNDED': return 'Paid and refunded to the balance';
+    default: return h('span', { class: 'reason' }, failureText(o));
+  }
+}
+
+const routes = [
+  [/^\/$/, () => shopPage()],
+  [/^\/history\/?$/, () => historyPage()],
+  [/^\/history\/([^/]+)$/, m => orderPage(decodeURIComponent(m[1]), false)],
+];
+
+function navigate(path) {
+  history.pushState(null, '', path);
+  route();
+}
+
+function route() {
+  clearTimeout(timer);
+  timer = null;
+  const path = location.pathname;
+  for (const a of document.querySelectorAll('header nav a')) {
+    const href = a.getAttribute('href');
+    a.classList.toggle('active', href === '/' ? path === '/' : path.startsWith(href));
+  }
+  if (!user()) {
+    signInPage();
+    return;
+  }
+  for (const [re, page] of routes) {
+    const m = path.match(re);
+    if (m) {
+      Promise.resolve(page(m)).catch(showError);
+      return;
+    }
+  }
+  view.replaceChildren(h('section', {}, h('h2', {}, 'Page not found'), link('/', 'Go to the shop')));
+}
+
+function renderSession() {
+  document.getElementById('session').hidden = !user();
+  document.getElementById('user').textContent = user();
+  if (user()) refreshBalance();
+}
+
+// Ссылки с data-link переключают страницу без перезагрузки; с модификаторами — как обычно, в новой вкладке
+document.addEventListener('click', e => {
+  const a = e.target.closest('a[data-link]');
+  if (!a || e.button !== 0 || e.metaKey || e.ctrlKey || e.shiftKey || e.altKey) return;
+  e.preventDefault();
+  e.stopPropagation();
+  if (a.getAttribute('href') !== location.pathname + location.search) navigate(a.getAttribute('href'));
+});
+
+document.getElementById('signOut').addEventListener('click', () => {
+  localStorage.removeItem(USER_KEY);
+  lastBalance = null;
+  renderSession();
+  navigate('/');
+});
+
+// Метка окружения видна везде, кроме prod, чтобы не перепутать стенды
+function renderEnvironment() {
+  const env = document.getElementById('env');
+  env.textContent = CONFIG.environment;
+  env.hidden = !CONFIG.environment || CONFIG.environment === 'prod';
+  document.getElementById('backoffice').hidden = !feature('backoffice_link');
+  document.getElementById('version').textContent = CONFIG.version ? `GoZon ${CONFIG.version}` : '';
+}
+
+window.addEventListener('popstate', route);
+renderEnvironment();
+renderSession();
+route();
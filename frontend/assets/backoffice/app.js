@@ -0,0 +1,462 @@
+// Бэк-офис поддержки. Всё ходит через gateway с токеном оператора (ADMIN_TOKENS),
+// оператора в сервисы gateway передаёт сам, по токену.
+'use strict';
+
+// Конфиг окружения из /config.js; без него — API на том же origin
+const CONFIG = Object.assign({ apiBase: '', environment: '' }, window.APP_CONFIG);
+const TOKEN_KEY = 'backoffice.token';
+const REFRESH_MS = 5000;
+const SERVICES = ['orders', 'payments'];
+
+const view = document.getElementById('view');
+const flashEl = document.getElementById('flash');
+const liveEl = document.getElementById('live');
+let timer = null;
+let flashTimer = null;
+let ordersFilter = { user_id: '', status: '', from: '', to: '' };
+let accountsFilter = { status: '', min_balance: '', max_balance: '', sort: 'balance_desc' };
+let showRedriven = false;
+
+function token() {
+  return sessionStorage.getItem(TOKEN_KEY) || '';
+}
+
+// api возвращает разобранный JSON; ошибка — Error с текстом из problem+json
+async function api(method, path, body) {
+  const headers = { Authorization: `Bearer ${token()}` };
+  if (body !== undefined) headers['Content-Type'] = 'application/json';
+  const res = await fetch(CONFIG.apiBase + path, { method, headers, body: body === undefined ? undefined : JSON.stringify(body) });
+  const text = await res.text();
+  let data = null;
+  try { data = text ? JSON.parse(text) : null; } catch (e) { data = null; }
+  if (!res.ok) {
+    const msg = data && data.code ? `${data.title}: ${data.detail || data.code} (${data.code})` : `HTTP ${res.status}`;
+    const err = new Error(msg);
+    err.status = res.status;
+    throw err;
+  }
+  return data;
+}
+
+function h(tag, attrs, ...children) {
+  const el = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k.startsWith('on')) el.addEventListener(k.slice(2), v);
+    else if (v === true) el.setAttribute(k, '');
+    else if (v !== undefined && v !== null && v !== false) el.setAttribute(k, v);
+  }
+  for (const c of children.flat()) {
+    if (c === undefined || c === null || c === false) continue;
+    el.append(c instanceof Node ? c : String(c));
+  }
+  return el;
+}
+
+function table(columns, rows, onClick) {
+  if (!rows || rows.length === 0) return h('p', { class: 'muted' }, 'Nothing here');
+  return h('table', {},
+    h('thead', {}, h('tr', {}, columns.map(([title]) => h('th', {}, title)))),
+    h('tbody', {}, rows.map(row => h('tr', onClick ? { class: 'link', onclick: () => onClick(row) } : {},
+      columns.map(([, cell]) => h('td', {}, cell(row)))))));
+}
+
+function kv(pairs) {
+  return h('div', { class: 'kv' }, pairs.flatMap(([k, v]) => [h('span', { class: 'muted' }, k), h('span', {}, v)]));
+}
+
+function badge(status) {
+  return h('span', { class: `status ${status}` }, status);
+}
+
+function when(t) {
+  return t ? new Date(t).toLocaleString() : '—';
+}
+
+function select(name, options, value) {
+  return h('select', { name }, options.map(([v, label]) => h('option', { value: v, selected: v === value }, label)));
+}
+
+function formValues(form) {
+  return Object.fromEntries(new FormData(form).entries());
+}
+
+// datetime-local без зоны; API ждёт RFC3339
+function rfc3339(local) {
+  return local ? new Date(local).toISOString() : '';
+}
+
+function eventType(payload) {
+  return payload && payload.type ? `${payload.type}.v${payload.version}` : 'legacy';
+}
+
+function flash(message, kind) {
+  clearTimeout(flashTimer);
+  flashEl.textContent = message;
+  flashEl.className = kind;
+  flashEl.hidden = false;
+  flashTimer = setTimeout(() => { flashEl.hidden = true; }, 6000);
+}
+
+function showError(err) {
+  flash(err.status === 401 ? 'Admin token rejected, sign in again' : err.message, 'error');
+}
+
+// live: загрузить сейчас и, если включено Live, обновлять раз в REFRESH_MS; таймер сбрасывается при переходе
+function live(load) {
+  const run = () => load().catch(showError);
+  run();
+  if (liveEl.checked) timer = setInterval(run, REFRESH_MS);
+  return run;
+}
+
+// action: подтверждение, запрос, сообщение и перезагрузка данных страницы
+async function action(question, fn, done, reload) {
+  if (question && !confirm(question)) return;
+  try {
+    await fn();
+    flash(done, 'ok');
+    reload();
+  } catch (err) {
+    showError(err);
+  }
+}
+
+// --- Заказы ---
+
+function ordersPage() {
+  const results = h('div');
+  const form = h('form', {
+    onsubmit: e => {
+      e.preventDefault();
+      ordersFilter = formValues(form);
+      reload();
+    },
+  },
+    h('label', {}, 'User'), h('input', { name: 'user_id', value: ordersFilter.user_id, placeholder: 'user-123' }),
+    h('label', {}, 'Status'), select('status', [['', 'any'], ['NEW', 'NEW'], ['FINISHED', 'FINISHED'], ['CANCELLED', 'CANCELLED'], ['REFUNDED', 'REFUNDED']], ordersFilter.status),
+    h('label', {}, 'From'), h('input', { name: 'from', type: 'datetime-local', value: ordersFilter.from }),
+    h('label', {}, 'To'), h('input', { name: 'to', type: 'datetime-local', value: ordersFilter.to }),
+    h('button', { type: 'submit' }, 'Search'));
+  view.replaceChildren(
+    h('section', {}, h('h2', {}, 'Find orders'), form),
+    h('section', {}, h('h2', {}, 'Orders'), results));
+
+  const reload = live(async () => {
+    const q = new URLSearchParams();
+    if (ordersFilter.user_id) q.set('user_id', ordersFilter.user_id.trim());
+    if (ordersFilter.status) q.set('status', ordersFilter.status);
+    if (ordersFilter.from) q.set('from', rfc3339(ordersFilter.from));
+    if (ordersFilter.to) q.set('to', rfc3339(ordersFilter.to));
+    results.replaceChildren(ordersTable(await api('GET', `/admin/orders?${q}`)));
+  });
+}
+
+function ordersTable(items) {
+  return table([
+    ['ID', o => o.id],
+    ['User', o => o.user_id],
+    ['Amount', o => o.amount],
+    ['Description', o => o.description],
+    ['Status', o => badge(o.status)],
+    ['Created', o => when(o.created_at)],
+  ], items, o => { location.hash = `#/orders/${o.id}`; });
+}
+
+function orderPage(id) {
+  const details = h('div');
+  const refundBtn = h('button', { type: 'submit', class: 'danger' }, 'Refund');
+  const refund = h('form', {
+    onsubmit: e => {
+      e.preventDefault();
+      const { reason } = formValues(refund);
+      action(`Refund order ${id}? The amount goes back to the customer's balance.`,
+        () => api('POST', `/admin/orders/${id}/refund`, { reason }), `Order ${id} refunded`, reload);
+    },
+  }, h('label', {}, 'Reason'), h('input', { name: 'reason', required: true }), refundBtn);
+  const force = h('form', {
+    onsubmit: e => {
+      e.preventDefault();
+      const { status, reason } = formValues(force);
+      action(`Set order ${id} to ${status}? No events are sent and no money moves.`,
+        () => api('POST', `/admin/orders/${id}/status`, { status, reason }), `Order ${id} is ${status} now`, reload);
+    },
+  },
+    select('status', [['CANCELLED', 'CANCELLED'], ['FINISHED', 'FINISHED'], ['NEW', 'NEW']], 'CANCELLED'),
+    h('label', {}, 'Reason'), h('input', { name: 'reason', required: true }),
+    h('button', { type: 'submit' }, 'Force status'));
+
+  view.replaceChildren(
+    h('p', {}, h('a', { href: '#/orders' }, '← Orders')),
+    details,
+    h('section', {}, h('h2', {}, 'Actions'),
+      h('p', {}, 'Refund a paid order'), refund,
+      h('p', {}, 'Fix the order status by hand'), force));
+
+  const reload = live(async () => {
+    const v = await api('GET', `/admin/orders/${id}`);
+    refundBtn.disabled = !(v.payment && v.payment.status === 'FINISHED');
+    details.replaceChildren(renderOrder(v));
+  });
+}
+
+function renderOrder(v) {
+  const o = v.order;
+  const received = new Set((v.payments_inbox || []).map(r => r.message_id));
+  const p = v.payment;
+  return h('div', { class: 'grid' },
+    h('section', {}, h('h2', {}, `Order ${o.id}`), kv([
+      ['User', h('a', { href: `#/accounts/${encodeURIComponent(o.user_id)}` }, o.user_id)],
+      ['Amount', o.amount],
+      ['Description', o.description || '—'],
+      ['Status', badge(o.status)],
+      ['Created', when(o.created_at)],
+    ])),
+    h('section', {}, h('h2', {}, 'Payment'),
+      v.errors && v.errors.payments ? h('p', { class: 'muted' }, `payments unavailable: ${v.errors.payments}`)
+        : p ? kv([
+          ['Status', badge(p.status)],
+          ['Reason', p.reason ? `${p.reason}${p.reason_code ? ` (${p.reason_code})` : ''}` : '—'],
+          ['Amount', p.amount],
+          ['Created', when(p.created_at)],
+        ]) : h('p', { class: 'muted' }, 'No payment yet')),
+    h('section', {}, h('h2', {}, 'Status history'), table([
+      ['From', c => c.from_status],
+      ['To', c => c.to_status],
+      ['Reason', c => c.reason],
+      ['Operator', c => c.operator_id],
+      ['At', c => when(c.created_at)],
+    ], v.status_history)),
+    h('section', {}, h('h2', {}, 'Events'),
+      h('p', {}, 'orders → payments'),
+      table([
+        ['Message', r => r.id],
+        ['Type', r => eventType(r.payload)],
+        ['Created', r => when(r.created_at)],
+        ['Published', r => r.published_at ? when(r.published_at) : 'pending'],
+        ['Received by payments', r => received.has(r.id) ? 'yes' : 'no'],
+      ], v.orders_outbox),
+      h('p', {}, 'payments → orders'),
+      table([
+        ['Message', r => r.id],
+        ['Type', r => eventType(r.payload)],
+        ['Created', r => when(r.created_at)],
+        ['Published', r => r.published_at ? when(r.published_at) : 'pending'],
+      ], v.payments_outbox || [])));
+}
+
+// --- Счета ---
+
+function accountsPage() {
+  const results = h('div');
+  const open = h('form', {
+    onsubmit: e => {
+      e.preventDefault();
+      const { user_id } = formValues(open);
+      if (user_id.trim()) location.hash = `#/accounts/${encodeURIComponent(user_id.trim())}`;
+    },
+  }, h('label', {}, 'User'), h('input', { name: 'user_id', placeholder: 'user-123' }), h('button', { type: 'submit' }, 'Open'));
+  const form = h('form', {
+    onsubmit: e => {
+      e.preventDefault();
+      accountsFilter = formValues(form);
+      reload();
+    },
+  },
+    h('label', {}, 'Status'), select('status', [['', 'any'], ['ACTIVE', 'ACTIVE'], ['FROZEN', 'FROZEN'], ['CLOSED', 'CLOSED']], accountsFilter.status),
+    h('label', {}, 'Balance from'), h('input', { name: 'min_balance', type: 'number', value: accountsFilter.min_balance }),
+    h('label', {}, 'to'), h('input', { name: 'max_balance', type: 'number', value: accountsFilter.max_balance }),
+    select('sort', [['balance_desc', 'largest first'], ['balance_asc', 'smallest first']], accountsFilter.sort),
+    h('button', { type: 'submit' }, 'Filter'));
+  view.replaceChildren(
+    h('section', {}, h('h2', {}, 'Open account'), open),
+    h('section', {}, h('h2', {}, 'Accounts'), form, results));
+
+  const reload = live(async () => {
+    const q = new URLSearchParams();
+    for (const [k, v] of Object.entries(accountsFilter)) if (v) q.set(k, v);
+    const items = await api('GET', `/admin/accounts?${q}`);
+    results.replaceChildren(table([
+      ['User', a => a.user_id],
+      ['Balance', a => a.balance],
+      ['Status', a => badge(a.status)],
+      ['Status reason', a => a.status_reason || '—'],
+      ['Created', a => when(a.created_at)],
+    ], items, a => { location.hash = `#/accounts/${encodeURIComponent(a.user_id)}`; }));
+  });
+}
+
+function accountPage(userID) {
+  const path = encodeURIComponent(userID);
+  const details = h('div');
+  const adjust = h('form', {
+    onsubmit: e => {
+      e.preventDefault();
+      const { amount, reason } = formValues(adjust);
+      action(`Adjust balance of ${userID} by ${amount}?`,
+        () => api('POST', `/admin/accounts/${path}/adjustments`, { amount: Number(amount), reason }), 'Balance adjusted', reload);
+    },
+  },
+    h('label', {}, 'Amount (negative to debit)'), h('input', { name: 'amount', type: 'number', required: true }),
+    h('label', {}, 'Reason'), h('input', { name: 'reason', required: true }),
+    h('button', { type: 'submit' }, 'Adjust'));
+  const status = h('form', {
+    onsubmit: e => {
+      e.preventDefault();
+      const { action: act, reason } = formValues(status);
+      action(`${act} account ${userID}?`,
+        () => api('POST', `/payments/admin/accounts/${path}/${act}`, { reason }), `Account ${act} done`, reload);
+    },
+  },
+    select('action', [['freeze', 'Freeze'], ['unfreeze', 'Unfreeze'], ['close', 'Close'], ['reopen', 'Reopen']], 'freeze'),
+    h('label', {}, 'Reason'), h('input', { name: 'reason', required: true }),
+    h('button', { type: 'submit', class: 'danger' }, 'Apply'));
+
+  view.replaceChildren(
+    h('p', {}, h('a', { href: '#/accounts' }, '← Accounts')),
+    details,
+    h('section', {}, h('h2', {}, 'Actions'),
+      h('p', {}, 'Balance adjustment'), adjust,
+      h('p', {}, 'Account status'), status));
+
+  const reload = live(async () => {
+    const [acc, limits, adjustments, orders] = await Promise.all([
+      api('GET', `/payments/accounts/${path}/balance`),
+      api('GET', `/payments/admin/accounts/${path}/limits`),
+      api('GET', `/admin/accounts/${path}/adjustments`),
+      api('GET', `/admin/orders?user_id=${path}&limit=20`),
+    ]);
+    details.replaceChildren(h('div', { class: 'grid' },
+      h('section', {}, h('h2', {}, `Account ${userID}`), kv([
+        ['Balance', acc.balance],
+        ['Status', badge(acc.status)],
+        ['Single payment limit', limits.max_single_payment || '—'],
+        ['Daily limit', limits.daily_limit || '—'],
+        ['Monthly limit', limits.monthly_limit || '—'],
+        ['Orders per hour', limits.max_orders_per_hour || '—'],
+        ['Review from', limits.review_amount || '—'],
+      ])),
+      h('section', {}, h('h2', {}, 'Adjustments'), table([
+        ['Amount', a => a.amount],
+        ['Balance after', a => a.balance_after],
+        ['Reason', a => a.reason],
+        ['Operator', a => a.operator_id],
+        ['At', a => when(a.created_at)],
+      ], adjustments)),
+      h('section', {}, h('h2', {}, 'Recent orders'), ordersTable(orders))));
+  });
+}
+
+// --- Сообщения ---
+
+function messagesPage() {
+  const results = h('div');
+  const toggle = h('input', {
+    type: 'checkbox',
+    checked: showRedriven,
+    onchange: () => {
+      showRedriven = toggle.checked;
+      reload();
+    },
+  });
+  view.replaceChildren(
+    h('p', {}, h('label', { class: 'inline' }, toggle, ' Show redriven dead letters')),
+    results);
+
+  const reload = live(async () => {
+    const data = await Promise.all(SERVICES.map(async svc => ({
+      svc,
+      backlog: await api('GET', `/${svc}/admin/replay/backlog`),
+      dead: await api('GET', `/${svc}/admin/replay/dead-letters${showRedriven ? '?all=true' : ''}`),
+    })));
+    results.replaceChildren(h('div', { class: 'grid' }, data.map(d => h('section', {},
+      h('h2', {}, d.svc),
+      kv([
+        ['Outbox pending', d.backlog.outbox.pending],
+        ['Oldest pending', when(d.backlog.outbox.oldest_created_at)],
+        ['Dead letters', d.backlog.dead_letters.pending],
+      ]),
+      h('p', {}, 'Dead letters'),
+      table([
+        ['ID', l => l.id],
+        ['Queue', l => l.queue],
+        ['Message', l => l.message_id || '—'],
+        ['Error', l => l.error],
+        ['Received', l => when(l.created_at)],
+        ['Body', l => h('pre', {}, l.body)],
+        ['', l => l.redriven_at
+          ? h('span', { class: 'muted' }, `redriven ${when(l.redriven_at)} by ${l.redriven_by}`)
+          : h('button', {
+            onclick: () => action(`Put message ${l.id} back to ${l.queue}?`,
+              () => api('POST', `/${d.svc}/admin/replay/dead-letters/${l.id}/redrive`), `Message ${l.id} redriven`, reload),
+          }, 'Redrive')],
+      ], d.dead)))));
+  });
+}
+
+// --- Навигация и вход ---
+
+const routes = [
+  [/^#\/orders\/(\d+)$/, m => orderPage(m[1])],
+  [/^#\/orders$/, () => ordersPage()],
+  [/^#\/accounts\/(.+)$/, m => accountPage(decodeURIComponent(m[1]))],
+  [/^#\/accounts$/, () => accountsPage()],
+  [/^#\/messages$/, () => messagesPage()],
+];
+
+function route() {
+  clearInterval(timer);
+  timer = null;
+  const hash = location.hash || '#/orders';
+  for (const a of document.querySelectorAll('header nav a')) {
+    a.classList.toggle('active', hash.startsWith(a.getAttribute('href')));
+  }
+  if (!token()) {
+    view.replaceChildren(h('p', { class: 'muted' }, 'Sign in with an admin token.'));
+    return;
+  }
+  for (const [re, page] of routes) {
+    const m = hash.match(re);
+    if (m) {
+      page(m);
+      return;
+    }
+  }
+  location.hash = '#/orders';
+}
+
+function renderSession() {
+  const signedIn = token() !== '';
+  document.getElementById('token').hidden = signedIn;
+  document.getElementById('signIn').textContent = signedIn ? 'Sign out' : 'Sign in';
+  document.getElementById('operator').textContent = signedIn ? 'Signed in' : '';
+}
+
+document.getElementById('signIn').addEventListener('click', async () => {
+  if (token()) {
+    sessionStorage.removeItem(TOKEN_KEY);
+    renderSession();
+    route();
+    return;
+  }
+  const input = document.getElementById('token');
+  sessionStorage.setItem(TOKEN_KEY, input.value.trim());
+  try {
+    // Проверяем токен дешёвым админским запросом
+    await api('GET', '/admin/accounts?limit=1');
+    input.value = '';
+  } catch (err) {
+    sessionStorage.removeItem(TOKEN_KEY);
+    showError(err);
+  }
+  renderSession();
+  route();
+});
+
+const envEl = document.getElementById('env');
+envEl.textContent = CONFIG.environment;
+envEl.hidden = !CONFIG.environment || CONFIG.environment === 'prod';
+
+liveEl.addEventListener('change', route);
+window.addEventListener('hashchange', route);
+renderSession();
+route();
@@ -0,0 +1,111 @@
+// Package appconfig — конфиг SPA из переменных окружения, браузер получает его в /config.js.
+// Один и тот же встроенный бандл работает в dev, staging и prod: отличается только окружение процесса.
+package appconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config уходит в браузер целиком — секретам здесь не место
+type Config struct {
+	// APIBase — префикс запросов к API; пусто — тот же origin, что у страницы (через gateway)
+	APIBase     string          `json:"apiBase"`
+	Environment string          `json:"environment"`
+	Version     string          `json:"version"`
+	Features    map[string]bool `json:"features"`
+}
+
+// defaultFeatures — флаги, которые знает фронт; FEATURE_FLAGS их перекрывает и может добавить новые
+var defaultFeatures = map[string]bool{
+	// ссылка на бэк-офис в шапке витрины
+	"backoffice_link": true,
+	// пополнение баланса с витрины
+	"deposit": true,
+}
+
+// FromEnv: API_BASE_URL, APP_ENV (dev), FEATURE_FLAGS ("deposit=false,new_checkout" — имя без значения = true).
+// Ошибки копятся и возвращаются разом, как в конфиге сервисов.
+func FromEnv(getenv func(string) string, version string) (Config, error) {
+	c := Config{
+		APIBase:     strings.TrimRight(strings.TrimSpace(getenv("API_BASE_URL")), "/"),
+		Environment: strings.TrimSpace(getenv("APP_ENV")),
+		Version:     version,
+		Features:    map[string]bool{},
+	}
+	if c.Environment == "" {
+		c.Environment = "dev"
+	}
+	for k, v := range defaultFeatures {
+		c.Features[k] = v
+	}
+
+	var errs []string
+	if c.APIBase != "" && !strings.HasPrefix(c.APIBase, "/") {
+		if u, err := url.Parse(c.APIBase); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("API_BASE_URL: %q is neither an absolute http(s) URL nor a path", c.APIBase))
+		}
+	}
+	for _, item := range strings.Split(getenv("FEATURE_FLAGS"), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(item, "=")
+		on := true
+		if hasValue {
+			var err error
+			if on, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+				errs = append(errs, fmt.Sprintf("FEATURE_FLAGS: %q: value must be true or false", item))
+				continue
+			}
+		}
+		c.Features[strings.TrimSpace(name)] = on
+	}
+	if len(errs) > 0 {
+		return Config{}, fmt.Errorf("frontend config: %s", strings.Join(errs, "; "))
+	}
+	return c, nil
+}
+
+// Handler отдаёт /config.js (window.APP_CONFIG для <script>) и /config.json. Конфиг меняется только
+// с перезапуском, поэтому тело считается один раз; no-cache + ETag — новый деплой виден сразу.
+type Handler struct {
+	js, json []byte
+	etag     string
+	started  time.Time
+}
+
+func NewHandler(c Config) (*Handler, error) {
+	// json.Marshal экранирует <, > и &, поэтому значение безопасно вставлять в скрипт
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return &Handler{
+		js:      []byte("window.APP_CONFIG = " + string(raw) + ";\n"),
+		json:    raw,
+		etag:    hex.EncodeToString(sum[:8]),
+		started: time.Now(),
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ctype, etag := h.js, "text/javascript; charset=utf-8", `"js-`+h.etag+`"`
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		body, ctype, etag = h.json, "application/json", `"json-`+h.etag+`"`
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", h.started, bytes.NewReader(body))
+}
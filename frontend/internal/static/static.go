@@ -0,0 +1,283 @@
+// Package static — раздача встроенных ассетов: отпечатки в именах файлов, кэш-заголовки, ETag,
+// gzip/brotli и SPA fallback только для известных клиентских маршрутов.
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/webshop/frontend/internal/logging"
+)
+
+const (
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// no-cache — браузер хранит копию, но каждый раз сверяет ETag: новый деплой виден сразу
+	cacheRevalidate = "no-cache"
+	// minCompressSize — мельче нет смысла сжимать, заголовки съедят выигрыш
+	minCompressSize = 256
+)
+
+type Options struct {
+	// Routes — префиксы клиентских маршрутов SPA. На /history и /history/... отдаётся index.html,
+	// на /backoffice/... — backoffice/index.html, раз он есть. Остальные пути без файла — 404.
+	Routes []string
+	// ModTime — Last-Modified для всех файлов; у embed.FS своего времени нет
+	ModTime time.Time
+}
+
+type asset struct {
+	body        []byte
+	gzip        []byte
+	br          []byte
+	etag        string
+	contentType string
+	immutable   bool
+}
+
+// Site держит все ассеты в памяти: отпечатки, сжатые версии и ETag считаются один раз на старте
+type Site struct {
+	assets  map[string]*asset
+	routes  []string
+	modTime time.Time
+}
+
+// New читает fsys. Рядом с файлом могут лежать file.gz / file.br, сжатые при сборке (precompress.sh):
+// brotli берётся только оттуда, gzip без готового файла считается здесь.
+func New(fsys fs.FS, opts Options) (*Site, error) {
+	files := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files["/"+p] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Site{assets: map[string]*asset{}, modTime: opts.ModTime}
+	for _, r := range opts.Routes {
+		if r = strings.TrimRight(strings.TrimSpace(r), "/"); r != "" {
+			s.routes = append(s.routes, r)
+		}
+	}
+
+	// Сначала всё, кроме HTML: отпечатки нужны, чтобы переписать ссылки в страницах
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if ext := path.Ext(name); ext != ".gz" && ext != ".br" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return !isHTML(names[i]) && isHTML(names[j]) })
+
+	fingerprints := map[string]string{}
+	for _, name := range names {
+		body := files[name]
+		a := &asset{body: body, contentType: contentType(name)}
+		if isHTML(name) {
+			body = rewrite(body, fingerprints)
+			a.body = body
+		}
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		a.etag = `"` + hash[:16] + `"`
+
+		// Готовые .gz/.br сжаты из исходного файла: HTML после подмены ссылок уже другой
+		if !isHTML(name) {
+			a.gzip, a.br = files[name+".gz"], files[name+".br"]
+		}
+		if a.gzip == nil && compressible(a.contentType) && len(body) >= minCompressSize {
+			if a.gzip, err = gzipBytes(body); err != nil {
+				return nil, fmt.Errorf("gzip %s: %w", name, err)
+			}
+		}
+		if a.gzip != nil && len(a.gzip) >= len(body) {
+			a.gzip = nil
+		}
+		if a.br != nil && len(a.br) >= len(body) {
+			a.br = nil
+		}
+		s.assets[name] = a
+
+		if !isHTML(name) {
+			ext := path.Ext(name)
+			fp := strings.TrimSuffix(name, ext) + "." + hash[:8] + ext
+			fingerprints[name] = fp
+			immutable := *a
+			immutable.immutable = true
+			s.assets[fp] = &immutable
+		}
+	}
+	return s, nil
+}
+
+// Stats — для лога на старте: сколько файлов и у скольких есть сжатые версии
+func (s *Site) Stats() (files, gzipped, brotli int) {
+	for _, a := range s.assets {
+		if a.immutable {
+			continue
+		}
+		files++
+		if a.gzip != nil {
+			gzipped++
+		}
+		if a.br != nil {
+			brotli++
+		}
+	}
+	return files, gzipped, brotli
+}
+
+func (s *Site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not supported here")
+		return
+	}
+	a, ok := s.assets[r.URL.Path]
+	if !ok {
+		a, ok = s.index(r.URL.Path)
+	}
+	if !ok {
+		writeProblem(w, r, http.StatusNotFound, "not_found", "no such endpoint")
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", a.contentType)
+	if a.immutable {
+		h.Set("Cache-Control", cacheImmutable)
+	} else {
+		h.Set("Cache-Control", cacheRevalidate)
+	}
+	body, etag := a.body, a.etag
+	if a.gzip != nil || a.br != nil {
+		h.Add("Vary", "Accept-Encoding")
+		ae := r.Header.Get("Accept-Encoding")
+		// У каждой кодировки свой ETag: это разные байты, кэш не должен их путать
+		switch {
+		case a.br != nil && accepts(ae, "br"):
+			body, etag = a.br, strings.TrimSuffix(etag, `"`)+`-br"`
+			h.Set("Content-Encoding", "br")
+		case a.gzip != nil && accepts(ae, "gzip"):
+			body, etag = a.gzip, strings.TrimSuffix(etag, `"`)+`-gzip"`
+			h.Set("Content-Encoding", "gzip")
+		}
+	}
+	h.Set("ETag", etag)
+	// ServeContent сам отвечает 304 на If-None-Match / If-Modified-Since и обрабатывает Range
+	http.ServeContent(w, r, "", s.modTime, bytes.NewReader(body))
+}
+
+// writeProblem — 404/405 в том же problem+json, что у gateway и сервисов: через gateway сюда падает
+// всё, что не совпало с API, и клиент не должен отличать ответ фронта по тексту
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	p := map[string]any{
+		"type":     "urn:webshop:problem:" + code,
+		"title":    http.StatusText(status),
+		"status":   status,
+		"code":     code,
+		"instance": r.URL.Path,
+		"detail":   detail,
+	}
+	if id := logging.RequestID(r.Context()); id != "" {
+		p["request_id"] = id
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// index — страница SPA для клиентского маршрута; корень отдаёт index.html всегда
+func (s *Site) index(p string) (*asset, bool) {
+	if p == "/" {
+		a, ok := s.assets["/index.html"]
+		return a, ok
+	}
+	for _, route := range s.routes {
+		if p != route && !strings.HasPrefix(p, route+"/") {
+			continue
+		}
+		if a, ok := s.assets[route+"/index.html"]; ok {
+			return a, true
+		}
+		a, ok := s.assets["/index.html"]
+		return a, ok
+	}
+	return nil, false
+}
+
+// rewrite подменяет в HTML ссылки вида "/app.js" на "/app.<hash>.js"
+func rewrite(html []byte, fingerprints map[string]string) []byte {
+	for name, fp := range fingerprints {
+		html = bytes.ReplaceAll(html, []byte(`"`+name+`"`), []byte(`"`+fp+`"`))
+	}
+	return html
+}
+
+func isHTML(name string) bool {
+	return path.Ext(name) == ".html"
+}
+
+func contentType(name string) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "svg")
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// accepts — есть ли coding в Accept-Encoding с ненулевым q
+func accepts(header, coding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(v, 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
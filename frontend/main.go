@@ -3,42 +3,101 @@ package main
 import (
 	"embed"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"path"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/example/webshop/frontend/internal/appconfig"
+	"github.com/example/webshop/frontend/internal/logging"
+	"github.com/example/webshop/frontend/internal/static"
 )
 
 //go:embed assets/*
 var staticFS embed.FS
 
+// version задаётся при сборке: -ldflags "-X main.version=1.4.0"
+var version = "dev"
+
 func main() {
+	logging.Setup("frontend", getenv("LOG_LEVEL", "info"), getenv("LOG_FORMAT", "json"))
 	port := getenv("PORT", "8083")
 	sub, err := fs.Sub(staticFS, "assets")
 	if err != nil {
-		log.Fatalf("embed fs: %v", err)
+		slog.Error("embed fs", "err", err)
+		os.Exit(1)
+	}
+	site, err := static.New(sub, static.Options{
+		Routes:  strings.Split(getenv("SPA_ROUTES", "/history,/backoffice"), ","),
+		ModTime: buildTime(),
+	})
+	if err != nil {
+		slog.Error("load assets", "err", err)
+		os.Exit(1)
+	}
+	files, gzipped, brotli := site.Stats()
+	slog.Info("assets loaded", "files", files, "gzip", gzipped, "brotli", brotli)
+
+	cfg, err := appconfig.FromEnv(os.Getenv, buildVersion())
+	if err != nil {
+		slog.Error("invalid config", "err", err)
+		os.Exit(1)
+	}
+	cfgHandler, err := appconfig.NewHandler(cfg)
+	if err != nil {
+		slog.Error("config handler", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("runtime config", "environment", cfg.Environment, "version", cfg.Version, "api_base", cfg.APIBase, "features", cfg.Features)
+	// CSP ставит gateway, а не фронт: проверить отсюда нельзя, поэтому хотя бы напоминаем
+	if cfg.APIBase != "" && !strings.HasPrefix(cfg.APIBase, "/") {
+		slog.Warn("API_BASE_URL is another origin: list it in gateway CSP_CONNECT_SRC and CORS_ALLOWED_ORIGINS, or the browser blocks API calls",
+			"api_base", cfg.APIBase)
 	}
-	fsHandler := http.FileServer(http.FS(sub))
 
-	http.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-	// Отдаём index для корня и любых мутных путей, чтобы SPA не падала
-		if r.URL.Path == "/" || path.Ext(r.URL.Path) == "" {
-			data, err := fs.ReadFile(sub, "index.html")
-			if err != nil {
-				http.Error(w, "index not found", http.StatusInternalServerError)
-				return
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	// index.html отдаётся на / и на клиентские маршруты из SPA_ROUTES, остальное без файла — 404:
+	// опечатка в пути API не должна превращаться в 200 с HTML.
+	// Бэк-офис — отдельная SPA под /backoffice (не /admin: там gateway требует токен уже на саму страницу)
+	http.Handle("/", site)
+	// Конфиг окружения для SPA: страницы подключают /config.js до своего app.js
+	http.Handle("/config.js", cfgHandler)
+	http.Handle("/config.json", cfgHandler)
+
+	slog.Info("listening", "port", port)
+	if err := http.ListenAndServe(":"+port, logging.Middleware(http.DefaultServeMux)); err != nil {
+		slog.Error("frontend server failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+// buildVersion — version из ldflags; локальная сборка без него — коммит из VCS-метаданных Go
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+				return "dev-" + s.Value[:12]
 			}
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write(data)
-			return
 		}
-		fsHandler.ServeHTTP(w, r)
-	}))
+	}
+	return version
+}
 
-	log.Printf("frontend listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("frontend server: %v", err)
+// buildTime — Last-Modified ассетов: они встроены в бинарь и меняются только вместе с ним
+func buildTime() time.Time {
+	if exe, err := os.Executable(); err == nil {
+		if fi, err := os.Stat(exe); err == nil {
+			return fi.ModTime()
+		}
 	}
+	return time.Now()
 }
 
 func getenv(key, fallback string) string {
@@ -47,4 +106,3 @@ func getenv(key, fallback string) string {
 	}
 	return fallback
 }
-
@@ -0,0 +1,16 @@
+#!/bin/sh
+# Сжимает текстовые ассеты заранее: рядом с файлом появляются file.gz и file.br,
+# сервер встраивает их вместе с оригиналами. Нет brotli/gzip в PATH — шаг пропускается,
+# gzip тогда посчитается на старте, brotli не будет.
+set -eu
+dir=${1:-assets}
+
+find "$dir" -type f \( -name '*.html' -o -name '*.css' -o -name '*.js' -o -name '*.svg' -o -name '*.json' \) |
+while read -r f; do
+  if command -v gzip >/dev/null 2>&1; then
+    gzip -9 -n -k -f "$f"
+  fi
+  if command -v brotli >/dev/null 2>&1; then
+    brotli -q 11 -k -f "$f"
+  fi
+done
@@ -0,0 +1,196 @@
+// Package app собирает роутер gateway; вынесен из main, чтобы e2e-тесты могли гонять его в своём процессе.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+
+	"github.com/example/webshop/gateway/internal/accesslog"
+	"github.com/example/webshop/gateway/internal/admin"
+	"github.com/example/webshop/gateway/internal/auth"
+	"github.com/example/webshop/gateway/internal/cors"
+	"github.com/example/webshop/gateway/internal/problem"
+	"github.com/example/webshop/gateway/internal/proxy"
+	"github.com/example/webshop/gateway/internal/security"
+	"github.com/example/webshop/gateway/internal/summary"
+	"github.com/example/webshop/gateway/internal/validate"
+)
+
+type Config struct {
+	// По одному или несколько инстансов на апстрим
+	OrdersURLs   []string
+	PaymentsURLs []string
+	FrontendURLs []string
+	// Upstream — балансировка, health check, таймауты, повторы и breaker; breaker у каждого инстанса свой
+	Upstream proxy.Options
+	// CORS выключен, пока не заданы AllowedOrigins
+	CORS     cors.Options
+	Security security.Options
+	// Audit — выборочная запись тел запросов/ответов; nil — выключено
+	Audit *accesslog.Auditor
+	// Validator — проверка запросов по OpenAPI; nil — выключено
+	Validator *validate.Validator
+	// AdminTokens — токен → ID оператора поддержки; пусто — админские маршруты закрыты (401)
+	AdminTokens map[string]string
+}
+
+// adminPrefixes — всё, что пускаем только с токеном оператора: бэк-офис, админки сервисов (replay, счета)
+// и их /debug (пул БД, внутренние счётчики)
+var adminPrefixes = []string{"/admin", "/orders/admin", "/payments/admin", "/orders/debug", "/payments/debug"}
+
+// Upstreams — формат файла со списками инстансов (UPSTREAMS_FILE); не указанный апстрим не меняется
+type Upstreams struct {
+	Orders   []string `yaml:"orders"`
+	Payments []string `yaml:"payments"`
+	Frontend []string `yaml:"frontend"`
+}
+
+type Gateway struct {
+	orders   *proxy.Pool
+	payments *proxy.Pool
+	frontend *proxy.Pool
+	handler  http.Handler
+
+	// modTime — версия файла апстримов, которую уже применили
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+func New(cfg Config) (*Gateway, error) {
+	orders, err := proxy.New("orders", cfg.OrdersURLs, cfg.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := proxy.New("payments", cfg.PaymentsURLs, cfg.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	frontend, err := proxy.New("frontend", cfg.FrontendURLs, cfg.Upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	r := chi.NewRouter()
+	// Request ID выдаётся здесь и уходит дальше заголовком X-Request-ID
+	r.Use(accesslog.Middleware(cfg.Audit))
+	r.Use(security.Headers(cfg.Security))
+	// preflight отвечаем сами, до апстримов он не доходит
+	r.Use(cors.Middleware(cfg.CORS))
+	r.Use(auth.Middleware(cfg.AdminTokens, adminPrefixes))
+	if cfg.Validator != nil {
+		r.Use(cfg.Validator.Middleware)
+	}
+	// Композиция: баланс и заказы пользователя одним запросом, апстримы опрашиваются параллельно
+	r.Get("/api/users/{user_id}/summary", summary.NewHandler(orders.Client(), payments.Client()).Get)
+	// Бэк-офис: карточка заказа собирается из обоих сервисов, остальное проксируется с тем же путём
+	r.Get("/admin/orders/{id}", admin.NewHandler(orders.Client(), payments.Client()).Order)
+	r.Post("/admin/orders/{id}/refund", admin.Refund(payments))
+	r.Handle("/admin/orders", orders)
+	r.Handle("/admin/orders/*", orders)
+	r.Handle("/admin/accounts", payments)
+	r.Handle("/admin/accounts/*", payments)
+	r.Mount("/orders", http.StripPrefix("/orders", orders))
+	r.Mount("/payments", http.StripPrefix("/payments", payments))
+	// Всё, что не схавали выше, отдаём фронту
+	r.NotFound(frontend.ServeHTTP)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
+
+	return &Gateway{orders: orders, payments: payments, frontend: frontend, handler: r}, nil
+}
+
+func (g *Gateway) Handler() http.Handler {
+	return g.handler
+}
+
+// Run крутит health check всех апстримов до отмены ctx
+func (g *Gateway) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range []*proxy.Pool{g.orders, g.payments, g.frontend} {
+		wg.Add(1)
+		go func(p *proxy.Pool) {
+			defer wg.Done()
+			p.Run(ctx)
+		}(p)
+	}
+	wg.Wait()
+}
+
+// LoadUpstreams перечитывает файл и подменяет списки инстансов. Файл проверяется целиком:
+// если хоть один URL битый, не меняется ничего.
+func (g *Gateway) LoadUpstreams(path string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	// битый файл не перечитываем каждый тик — ждём следующей правки
+	g.modTime = info.ModTime()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var ups Upstreams
+	if err := yaml.Unmarshal(raw, &ups); err != nil {
+		return fmt.Errorf("upstreams file %s: %w", path, err)
+	}
+
+	type update struct {
+		pool *proxy.Pool
+		urls []string
+	}
+	var updates []update
+	for _, u := range []update{{g.orders, ups.Orders}, {g.payments, ups.Payments}, {g.frontend, ups.Frontend}} {
+		if len(u.urls) == 0 {
+			continue
+		}
+		if err := proxy.ValidateURLs(u.urls); err != nil {
+			return fmt.Errorf("upstreams file %s: %w", path, err)
+		}
+		updates = append(updates, u)
+	}
+	var errs []error
+	for _, u := range updates {
+		errs = append(errs, u.pool.Update(u.urls))
+	}
+	return errors.Join(errs...)
+}
+
+// WatchUpstreams раз в interval смотрит на mtime файла и перечитывает его, если он поменялся
+func (g *Gateway) WatchUpstreams(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.Warn("upstreams file stat failed", "path", path, "err", err)
+			continue
+		}
+		g.mu.Lock()
+		changed := !info.ModTime().Equal(g.modTime)
+		g.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if err := g.LoadUpstreams(path); err != nil {
+			slog.Error("upstreams reload failed, keeping current instances", "path", path, "err", err)
+			continue
+		}
+		slog.Info("upstreams reloaded", "path", path)
+	}
+}
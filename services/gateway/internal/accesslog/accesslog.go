@@ -0,0 +1,171 @@
+// Package accesslog — access-лог gateway и выборочный аудит тел запросов/ответов.
+package accesslog
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/webshop/gateway/internal/logging"
+)
+
+// info заполняется по ходу запроса: прокси пишет, какой апстрим и инстанс ответили
+type info struct {
+	mu       sync.Mutex
+	upstream string
+	instance string
+	user     string
+}
+
+type ctxKey struct{}
+
+// SetUpstream — вызывается прокси на каждой попытке, в логе остаётся последний инстанс
+func SetUpstream(ctx context.Context, upstream, instance string) {
+	if in, ok := ctx.Value(ctxKey{}).(*info); ok {
+		in.mu.Lock()
+		in.upstream, in.instance = upstream, instance
+		in.mu.Unlock()
+	}
+}
+
+// SetUser — кто сделал запрос, если это стало известно глубже middleware
+func SetUser(ctx context.Context, user string) {
+	if in, ok := ctx.Value(ctxKey{}).(*info); ok {
+		in.mu.Lock()
+		in.user = user
+		in.mu.Unlock()
+	}
+}
+
+// Middleware выдаёт request ID (как logging.Middleware в сервисах), пишет access-лог
+// и, если аудит включён и маршрут выбран, — запись с телами запроса и ответа
+func Middleware(audit *Auditor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(logging.RequestIDHeader)
+			if id == "" {
+				id = logging.NewRequestID()
+				r.Header.Set(logging.RequestIDHeader, id)
+			}
+			in := &info{}
+			ctx := context.WithValue(logging.WithRequestID(r.Context(), id), ctxKey{}, in)
+			r = r.WithContext(ctx)
+
+			var capture *capture
+			if audit.sample(r) {
+				capture = audit.captureRequest(r)
+			}
+
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w, requestID: id, status: http.StatusOK, capture: capture}
+			next.ServeHTTP(rw, r)
+			if !rw.wroteHeader {
+				rw.WriteHeader(http.StatusOK)
+			}
+			latency := time.Since(start)
+
+			in.mu.Lock()
+			upstream, instance, user := in.upstream, in.instance, in.user
+			in.mu.Unlock()
+			if user == "" {
+				user = userFromRequest(r)
+			}
+			if user == "" && capture != nil {
+				user = capture.userID
+			}
+
+			logging.FromContext(ctx).Info("http request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", routePattern(r),
+				"upstream", upstream,
+				"instance", instance,
+				"status", rw.status,
+				"latency_ms", latency.Milliseconds(),
+				"bytes_in", max(r.ContentLength, 0),
+				"bytes", rw.bytes,
+				"user", user,
+				"remote_addr", r.RemoteAddr,
+			)
+
+			if capture != nil {
+				audit.write(entry{
+					Time:      start.UTC(),
+					RequestID: id,
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					Query:     r.URL.RawQuery,
+					Route:     routePattern(r),
+					Upstream:  upstream,
+					Instance:  instance,
+					User:      user,
+					Status:    rw.status,
+					LatencyMs: latency.Milliseconds(),
+					Request:   capture.request,
+					Response:  audit.response(rw.Header(), capture),
+				})
+			}
+		})
+	}
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+// userFromRequest — аутентификации пока нет, пользователя видно только по параметрам запроса
+func userFromRequest(r *http.Request) string {
+	if u := r.Header.Get("X-User-ID"); u != "" {
+		return u
+	}
+	if u := r.URL.Query().Get("user_id"); u != "" {
+		return u
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.URLParam("user_id")
+	}
+	return ""
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	requestID   string
+	status      int
+	bytes       int
+	wroteHeader bool
+	capture     *capture
+}
+
+// WriteHeader ставит X-Request-ID в последний момент: прокси копирует заголовки апстрима,
+// и без Set в ответе оказалось бы два одинаковых значения
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+		w.Header().Set(logging.RequestIDHeader, w.requestID)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	if w.capture != nil {
+		w.capture.response.write(b[:n])
+	}
+	return n, err
+}
+
+// Unwrap нужен http.ResponseController (Flush у стриминговых ответов)
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
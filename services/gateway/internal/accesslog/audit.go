@@ -0,0 +1,218 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+type AuditOptions struct {
+	// Routes — какие запросы писать: "POST /orders" или просто префикс пути "/payments/admin/"
+	Routes []string
+	// SampleRate — доля подходящих запросов, 0..1
+	SampleRate float64
+	// MaxBodyBytes — больше не пишем: обрезанный JSON не замаскировать, поэтому тело пропускается целиком
+	MaxBodyBytes int
+	// RedactFields — ключи JSON (на любой глубине, без учёта регистра), значения которых маскируются
+	RedactFields  []string
+	RedactHeaders []string
+}
+
+// Auditor пишет JSON lines в w (обычно RotatingFile). nil — аудит выключен.
+type Auditor struct {
+	opts          AuditOptions
+	routes        []auditRoute
+	redactFields  map[string]bool
+	redactHeaders map[string]bool
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type auditRoute struct {
+	method string
+	prefix string
+}
+
+func NewAuditor(w io.Writer, opts AuditOptions) *Auditor {
+	a := &Auditor{opts: opts, w: w, redactFields: map[string]bool{}, redactHeaders: map[string]bool{}}
+	for _, r := range opts.Routes {
+		method, prefix, ok := strings.Cut(strings.TrimSpace(r), " ")
+		if !ok {
+			method, prefix = "", method
+		}
+		a.routes = append(a.routes, auditRoute{method: strings.ToUpper(method), prefix: strings.TrimSpace(prefix)})
+	}
+	for _, f := range opts.RedactFields {
+		a.redactFields[strings.ToLower(f)] = true
+	}
+	for _, h := range opts.RedactHeaders {
+		a.redactHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+	return a
+}
+
+func (a *Auditor) sample(r *http.Request) bool {
+	if a == nil || a.opts.SampleRate <= 0 {
+		return false
+	}
+	for _, route := range a.routes {
+		if (route.method == "" || route.method == r.Method) && strings.HasPrefix(r.URL.Path, route.prefix) {
+			return a.opts.SampleRate >= 1 || rand.Float64() < a.opts.SampleRate
+		}
+	}
+	return false
+}
+
+type capture struct {
+	request  message
+	response limitedBuffer
+	userID   string
+}
+
+// message — запрос или ответ в записи аудита
+type message struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"`
+	Size    int64             `json:"size"`
+	// Note — почему тела нет в записи
+	Note string `json:"note,omitempty"`
+}
+
+type entry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Query     string    `json:"query,omitempty"`
+	Route     string    `json:"route,omitempty"`
+	Upstream  string    `json:"upstream,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Status    int       `json:"status"`
+	LatencyMs int64     `json:"latency_ms"`
+	Request   message   `json:"request"`
+	Response  message   `json:"response"`
+}
+
+// captureRequest читает начало тела и подкладывает его обратно, чтобы прокси отправил запрос целиком
+func (a *Auditor) captureRequest(r *http.Request) *capture {
+	c := &capture{response: limitedBuffer{limit: a.opts.MaxBodyBytes}}
+	c.request.Headers = a.headers(r.Header)
+
+	var buf limitedBuffer
+	buf.limit = a.opts.MaxBodyBytes
+	if r.Body != nil && r.Body != http.NoBody {
+		head, _ := io.ReadAll(io.LimitReader(r.Body, int64(a.opts.MaxBodyBytes)+1))
+		buf.write(head)
+		r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+	}
+	if buf.truncated && r.ContentLength > 0 {
+		buf.total = r.ContentLength
+	}
+	c.request.Body, c.request.Note = a.body(r.Header.Get("Content-Type"), &buf)
+	c.request.Size = buf.total
+	if m, ok := c.request.Body.(map[string]any); ok {
+		c.userID, _ = m["user_id"].(string)
+	}
+	return c
+}
+
+func (a *Auditor) response(h http.Header, c *capture) message {
+	msg := message{Headers: a.headers(h), Size: c.response.total}
+	msg.Body, msg.Note = a.body(h.Get("Content-Type"), &c.response)
+	return msg
+}
+
+func (a *Auditor) headers(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if a.redactHeaders[k] {
+			out[k] = redacted
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
+
+// body пишет в аудит только JSON, и только после маскирования: в произвольном тексте секрет не найти
+func (a *Auditor) body(contentType string, buf *limitedBuffer) (any, string) {
+	switch {
+	case buf.total == 0:
+		return nil, ""
+	case buf.truncated:
+		return nil, "body exceeds audit limit"
+	case !strings.Contains(contentType, "json"):
+		return nil, "non-JSON body not recorded"
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf.buf.Bytes()))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, "invalid JSON body not recorded"
+	}
+	return a.redact(v), ""
+}
+
+func (a *Auditor) redact(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if a.redactFields[strings.ToLower(k)] {
+				t[k] = redacted
+				continue
+			}
+			t[k] = a.redact(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = a.redact(val)
+		}
+	}
+	return v
+}
+
+func (a *Auditor) write(e entry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("audit entry encode failed", "err", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(append(line, '\n')); err != nil {
+		slog.Error("audit write failed", "err", err)
+	}
+}
+
+// limitedBuffer копит до limit байт, дальше только считает
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	total     int64
+	truncated bool
+}
+
+func (b *limitedBuffer) write(p []byte) {
+	b.total += int64(len(p))
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	if b.total > int64(b.limit) {
+		b.truncated = true
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,88 @@
+// Package admin — карточка заказа для бэк-офиса: заказ и его outbox из orders, оплата, outbox и inbox из payments.
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/webshop/gateway/internal/upstream"
+)
+
+// Handler ходит в апстримы через proxy.Pool.Client(), как и сводка пользователя
+type Handler struct {
+	orders   *http.Client
+	payments *http.Client
+}
+
+func NewHandler(orders, payments *http.Client) *Handler {
+	return &Handler{orders: orders, payments: payments}
+}
+
+type OrderView struct {
+	Order          json.RawMessage   `json:"order"`
+	StatusHistory  json.RawMessage   `json:"status_history"`
+	OrdersOutbox   []json.RawMessage `json:"orders_outbox"`
+	Payment        json.RawMessage   `json:"payment"`
+	PaymentsOutbox json.RawMessage   `json:"payments_outbox"`
+	// PaymentsInbox — какие события заказа payments уже принял (message_id = ID строки outbox orders)
+	PaymentsInbox json.RawMessage   `json:"payments_inbox"`
+	Errors        map[string]string `json:"errors,omitempty"`
+}
+
+// Order: без orders карточки нет — его ошибку отдаём как есть; payments упал — раздел null и причина в errors
+func (h *Handler) Order(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var fromOrders struct {
+		Order         json.RawMessage   `json:"order"`
+		StatusHistory json.RawMessage   `json:"status_history"`
+		Outbox        []json.RawMessage `json:"outbox"`
+	}
+	if err := upstream.GetJSON(r.Context(), h.orders, r, "http://orders/admin/orders/"+url.PathEscape(id), &fromOrders); err != nil {
+		upstream.WriteError(w, r, err)
+		return
+	}
+
+	q := url.Values{}
+	for _, rec := range fromOrders.Outbox {
+		var msg struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(rec, &msg) == nil && msg.ID != "" {
+			q.Add("message_id", msg.ID)
+		}
+	}
+	var fromPayments struct {
+		Payment json.RawMessage `json:"payment"`
+		Outbox  json.RawMessage `json:"outbox"`
+		Inbox   json.RawMessage `json:"inbox"`
+	}
+	res := OrderView{
+		Order:         fromOrders.Order,
+		StatusHistory: fromOrders.StatusHistory,
+		OrdersOutbox:  fromOrders.Outbox,
+	}
+	paymentsURL := "http://payments/admin/payments/" + url.PathEscape(id) + "?" + q.Encode()
+	if err := upstream.GetJSON(r.Context(), h.payments, r, paymentsURL, &fromPayments); err != nil {
+		res.Errors = map[string]string{"payments": err.Error()}
+	} else {
+		res.Payment, res.PaymentsOutbox, res.PaymentsInbox = fromPayments.Payment, fromPayments.Outbox, fromPayments.Inbox
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// Refund — деньги возвращает payments (/admin/payments/{id}/refund), а в бэк-офисе это действие над заказом:
+// запрос уходит в пул payments с переписанным путём
+func Refund(payments http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := r.Clone(r.Context())
+		out.URL.Path = "/admin/payments/" + url.PathEscape(chi.URLParam(r, "id")) + "/refund"
+		out.URL.RawPath = ""
+		payments.ServeHTTP(w, out)
+	}
+}
@@ -0,0 +1,91 @@
+// Package auth — доступ к админским маршрутам gateway по bearer-токенам операторов поддержки.
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/example/webshop/gateway/internal/accesslog"
+	"github.com/example/webshop/gateway/internal/problem"
+)
+
+// OperatorHeader — кто выполняет админское действие; сервисы доверяют ему, поэтому от клиента он не принимается
+const OperatorHeader = "X-Operator-ID"
+
+const CodeUnauthorized = "unauthorized"
+
+type operator struct {
+	id    string
+	token []byte
+}
+
+// ParseTokens разбирает ADMIN_TOKENS: "alice:token1,bob:token2" → токен → ID оператора
+func ParseTokens(items []string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, item := range items {
+		id, token, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || id == "" || token == "" {
+			return nil, fmt.Errorf("admin token %q: want operator:token", item)
+		}
+		if _, dup := tokens[token]; dup {
+			return nil, fmt.Errorf("admin token of %q is used twice", id)
+		}
+		tokens[token] = id
+	}
+	return tokens, nil
+}
+
+// Middleware пускает на пути с префиксами из prefixes только с Authorization: Bearer <токен>.
+// Без токенов админка закрыта целиком. Оператор уходит в сервисы заголовком X-Operator-ID и в access-лог.
+func Middleware(tokens map[string]string, prefixes []string) func(http.Handler) http.Handler {
+	ops := make([]operator, 0, len(tokens))
+	for token, id := range tokens {
+		ops = append(ops, operator{id: id, token: []byte(token)})
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Del(OperatorHeader)
+			if !protected(r.URL.Path, prefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, ok := authenticate(ops, r.Header.Get("Authorization"))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				problem.Error(w, r, http.StatusUnauthorized, CodeUnauthorized, "valid admin bearer token required")
+				return
+			}
+			// Токен дальше gateway не уходит, сервисам хватает ID оператора
+			r.Header.Del("Authorization")
+			r.Header.Set(OperatorHeader, id)
+			accesslog.SetUser(r.Context(), "operator:"+id)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func protected(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate сравнивает со всеми токенами за постоянное время, чтобы по задержке нельзя было подбирать токен
+func authenticate(ops []operator, header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	var found string
+	for _, op := range ops {
+		if subtle.ConstantTimeCompare(op.token, []byte(token)) == 1 {
+			found = op.id
+		}
+	}
+	return found, found != ""
+}
@@ -0,0 +1,63 @@
+// Package problem — ошибки gateway в формате RFC 7807 (application/problem+json), как в сервисах.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/example/webshop/gateway/internal/logging"
+)
+
+const ContentType = "application/problem+json"
+
+// Коды ошибок самого gateway; ошибки сервисов проходят через прокси как есть, со своими кодами
+const (
+	CodeValidation          = "validation_failed"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeUpstreamTimeout     = "upstream_timeout"
+	CodeCircuitOpen         = "upstream_circuit_open"
+	CodeNoHealthyUpstream   = "no_healthy_upstream"
+)
+
+// New — тело ошибки; дополнительные поля (upstream, violations) кладутся в map до Write
+func New(r *http.Request, status int, code, detail string) map[string]any {
+	p := map[string]any{
+		"type":     "urn:webshop:problem:" + code,
+		"title":    http.StatusText(status),
+		"status":   status,
+		"code":     code,
+		"instance": instance(r),
+	}
+	if detail != "" {
+		p["detail"] = detail
+	}
+	if id := logging.RequestID(r.Context()); id != "" {
+		p["request_id"] = id
+	}
+	return p
+}
+
+// instance — путь, с которым пришёл клиент: StripPrefix перед прокси уже отрезал /orders из r.URL
+func instance(r *http.Request) string {
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		return u.Path
+	}
+	return r.URL.Path
+}
+
+func Write(w http.ResponseWriter, status int, p map[string]any) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Error — ошибка без дополнительных полей
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, status, New(r, status, code, detail))
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not supported here")
+}
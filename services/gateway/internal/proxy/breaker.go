@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrOpen — breaker открыт, запрос в апстрим не уходит
+var ErrOpen = errors.New("circuit breaker open")
+
+const (
+	stateClosed = "closed"
+	stateOpen   = "open"
+	// halfOpen — кулдаун прошёл, пропускаем один пробный запрос
+	stateHalfOpen = "half_open"
+)
+
+// breaker считает подряд идущие сбои инстанса апстрима. После failures сбоев открывается на cooldown
+// и отвечает ErrOpen без похода в сеть; затем пропускает один пробный запрос:
+// успех закрывает breaker, сбой открывает его снова.
+type breaker struct {
+	name     string
+	instance string
+	failures int
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failed   int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(name, instance string, failures int, cooldown time.Duration) *breaker {
+	return &breaker{name: name, instance: instance, failures: failures, cooldown: cooldown, state: stateClosed}
+}
+
+// allow решает, можно ли идти в апстрим. Выключенный breaker (failures <= 0) пропускает всё.
+func (b *breaker) allow() error {
+	if b.failures <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrOpen
+		}
+		b.setState(stateHalfOpen)
+		b.probing = true
+		return nil
+	case stateHalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+func (b *breaker) success() {
+	if b.failures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed = 0
+	b.probing = false
+	if b.state != stateClosed {
+		b.setState(stateClosed)
+	}
+}
+
+func (b *breaker) failure() {
+	if b.failures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed++
+	b.probing = false
+	if b.state == stateHalfOpen || (b.state == stateClosed && b.failed >= b.failures) {
+		b.openedAt = time.Now()
+		b.setState(stateOpen)
+	}
+}
+
+// release — исход запроса ничего не говорит об апстриме (клиент ушёл сам): просто отпускаем пробу
+func (b *breaker) release() {
+	if b.failures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// retryAfter — сколько осталось до пробного запроса, для заголовка Retry-After
+func (b *breaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if left := b.cooldown - time.Since(b.openedAt); left > 0 {
+		return left
+	}
+	return 0
+}
+
+func (b *breaker) setState(state string) {
+	level := slog.LevelWarn
+	if state == stateClosed {
+		level = slog.LevelInfo
+	}
+	slog.Log(context.Background(), level, "circuit breaker state changed", "upstream", b.name, "instance", b.instance, "from", b.state, "to", state, "failures", b.failed)
+	b.state = state
+}
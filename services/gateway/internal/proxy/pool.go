@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoBackend — у апстрима нет ни одного живого инстанса
+var ErrNoBackend = errors.New("no healthy upstream instance")
+
+const (
+	BalanceRoundRobin = "round_robin"
+	BalanceLeastConn  = "least_conn"
+)
+
+// backend — один инстанс апстрима со своим breaker, флагом health check и счётчиком запросов в полёте
+type backend struct {
+	url     *url.URL
+	breaker *breaker
+	healthy atomic.Bool
+	active  atomic.Int64
+}
+
+// Pool — список инстансов одного апстрима. Список можно заменить на ходу через Update:
+// у инстансов, которые остались, сохраняются breaker и статус здоровья.
+type Pool struct {
+	name      string
+	opts      Options
+	handler   http.Handler
+	transport *transport
+
+	mu       sync.Mutex
+	backends atomic.Pointer[[]*backend]
+	next     atomic.Uint64
+	health   *http.Client
+}
+
+// ValidateURLs проверяет список инстансов, ничего не меняя
+func ValidateURLs(urls []string) error {
+	_, err := parseURLs(urls)
+	return err
+}
+
+func parseURLs(urls []string) ([]*url.URL, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("empty upstream list")
+	}
+	parsed := make([]*url.URL, 0, len(urls))
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid upstream url %q", raw)
+		}
+		parsed = append(parsed, u)
+	}
+	return parsed, nil
+}
+
+// Update заменяет список инстансов. Новые инстансы считаются живыми до первой проверки.
+func (p *Pool) Update(urls []string) error {
+	parsed, err := parseURLs(urls)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p.name, err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := map[string]*backend{}
+	if old := p.backends.Load(); old != nil {
+		for _, b := range *old {
+			current[b.url.String()] = b
+		}
+	}
+	next := make([]*backend, 0, len(parsed))
+	for _, u := range parsed {
+		if b, ok := current[u.String()]; ok {
+			next = append(next, b)
+			continue
+		}
+		b := &backend{url: u, breaker: newBreaker(p.name, u.Host, p.opts.BreakerFailures, p.opts.BreakerCooldown)}
+		b.healthy.Store(true)
+		next = append(next, b)
+	}
+	p.backends.Store(&next)
+	slog.Info("upstream instances updated", "upstream", p.name, "instances", p.URLs())
+	return nil
+}
+
+// URLs — текущий список инстансов
+func (p *Pool) URLs() []string {
+	list := *p.backends.Load()
+	urls := make([]string, len(list))
+	for i, b := range list {
+		urls[i] = b.url.String()
+	}
+	return urls
+}
+
+// pick выбирает инстанс для очередной попытки: только живые, ещё не пробованные в этом запросе
+// и с закрытым (или готовым к пробе) breaker. Порядок обхода задаёт стратегия балансировки.
+func (p *Pool) pick(tried []*backend) (*backend, error) {
+	list := *p.backends.Load()
+	start := int(p.next.Add(1) - 1)
+	candidates := make([]*backend, 0, len(list))
+	for i := range list {
+		b := list[(start+i)%len(list)]
+		if b.healthy.Load() && !slices.Contains(tried, b) {
+			candidates = append(candidates, b)
+		}
+	}
+	if p.opts.Balance == BalanceLeastConn {
+		// стабильная сортировка: при равной загрузке остаётся round-robin порядок
+		slices.SortStableFunc(candidates, func(a, b *backend) int {
+			return int(a.active.Load() - b.active.Load())
+		})
+	}
+
+	err := ErrNoBackend
+	for _, b := range candidates {
+		if berr := b.breaker.allow(); berr != nil {
+			err = berr
+			continue
+		}
+		return b, nil
+	}
+	return nil, err
+}
+
+// retryAfter — ближайший момент, когда какой-нибудь breaker пропустит пробу
+func (p *Pool) retryAfter() time.Duration {
+	var soonest time.Duration
+	for _, b := range *p.backends.Load() {
+		if d := b.breaker.retryAfter(); d > 0 && (soonest == 0 || d < soonest) {
+			soonest = d
+		}
+	}
+	return soonest
+}
+
+// Run гоняет активный health check, пока жив ctx; HealthInterval <= 0 — проверок нет
+func (p *Pool) Run(ctx context.Context) {
+	if p.opts.HealthInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		p.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range *p.backends.Load() {
+		wg.Add(1)
+		go func(b *backend) {
+			defer wg.Done()
+			err := p.check(ctx, b)
+			if ctx.Err() != nil {
+				return
+			}
+			healthy := err == nil
+			if b.healthy.Swap(healthy) != healthy {
+				if healthy {
+					slog.Info("upstream instance is healthy", "upstream", p.name, "instance", b.url.Host)
+				} else {
+					slog.Warn("upstream instance is unhealthy", "upstream", p.name, "instance", b.url.Host, "err", err)
+				}
+			}
+		}(b)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) check(ctx context.Context, b *backend) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url.JoinPath(p.opts.HealthPath).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.health.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("health check status %d", resp.StatusCode)
+	}
+	return nil
+}
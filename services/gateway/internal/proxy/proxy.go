@@ -0,0 +1,253 @@
+// Package proxy — reverse proxy до апстрима из нескольких инстансов: балансировка, health check,
+// таймауты, повторы идемпотентных запросов и circuit breaker на каждый инстанс.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/webshop/gateway/internal/accesslog"
+	"github.com/example/webshop/gateway/internal/problem"
+)
+
+// Options — нулевое значение везде значит «выключено»: без таймаута, без повторов, без breaker и health check
+type Options struct {
+	// DialTimeout — установка TCP-соединения, ResponseHeaderTimeout — ожидание заголовков ответа,
+	// Timeout — весь запрос целиком, включая повторы
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	Timeout               time.Duration
+
+	// Retries — сколько раз повторить GET/HEAD после сетевой ошибки или 502/503/504;
+	// повтор идёт на другой инстанс, пауза растёт вдвое от RetryBackoff со случайным разбросом
+	Retries      int
+	RetryBackoff time.Duration
+
+	// BreakerFailures подряд идущих сбоев инстанса открывают его breaker на BreakerCooldown
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	// Balance — round_robin (по умолчанию) или least_conn
+	Balance string
+
+	// Активный health check: GET HealthPath раз в HealthInterval, не 2xx — инстанс выводится из ротации
+	HealthPath     string
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+}
+
+// New собирает прокси до инстансов urls; name попадает в логи и в тело ошибок
+func New(name string, urls []string, opts Options) (*Pool, error) {
+	p := &Pool{
+		name:   name,
+		opts:   opts,
+		health: &http.Client{Timeout: opts.HealthTimeout},
+	}
+	if err := p.Update(urls); err != nil {
+		return nil, err
+	}
+
+	p.transport = &transport{
+		pool: p,
+		base: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   opts.DialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   32,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+	proxy := &httputil.ReverseProxy{
+		// Инстанс выбирает transport на каждую попытку, тут только заглушка
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = name
+			if _, ok := req.Header["User-Agent"]; !ok {
+				req.Header.Set("User-Agent", "")
+			}
+		},
+		Transport: p.transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeError(w, r, p, err)
+		},
+	}
+
+	p.handler = proxy
+	if opts.Timeout > 0 {
+		p.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), opts.Timeout)
+			defer cancel()
+			proxy.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	return p, nil
+}
+
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.handler.ServeHTTP(w, r)
+}
+
+// Client — HTTP-клиент поверх того же пула: балансировка, повторы и breaker те же, что у прокси.
+// Хост в URL запроса не важен, инстанс выбирается на каждую попытку: http://orders/?user_id=42
+func (p *Pool) Client() *http.Client {
+	return &http.Client{Transport: p.transport, Timeout: p.opts.Timeout}
+}
+
+type transport struct {
+	pool *Pool
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts := t.pool.opts
+	attempts := 1
+	if idempotent(req) {
+		attempts += opts.Retries
+	}
+	ctx := req.Context()
+	var tried []*backend
+	for attempt := 1; ; attempt++ {
+		b, err := t.pool.pick(tried)
+		if errors.Is(err, ErrNoBackend) && len(tried) > 0 {
+			// другие инстансы кончились — повторяем на уже пробованных
+			tried = tried[:0]
+			b, err = t.pool.pick(tried)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tried = append(tried, b)
+
+		resp, err := t.send(req, b)
+		failed := err != nil || retryableStatus(resp.StatusCode)
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			// клиент ушёл сам — инстанс тут ни при чём
+			b.breaker.release()
+		case failed:
+			b.breaker.failure()
+		default:
+			b.breaker.success()
+		}
+		if !failed || attempt >= attempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		wait := backoff(opts.RetryBackoff, attempt)
+		slog.Warn("retrying upstream request",
+			"upstream", t.pool.name, "instance", b.url.Host, "method", req.Method, "path", req.URL.Path,
+			"attempt", attempt, "status", status, "err", err, "wait", wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send отправляет попытку в конкретный инстанс; запрос в полёте считается до закрытия тела ответа
+func (t *transport) send(req *http.Request, b *backend) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = b.url.Scheme
+	out.URL.Host = b.url.Host
+	if prefix := strings.TrimSuffix(b.url.Path, "/"); prefix != "" {
+		out.URL.Path = prefix + out.URL.Path
+		out.URL.RawPath = ""
+	}
+
+	accesslog.SetUpstream(req.Context(), t.pool.name, b.url.Host)
+	b.active.Add(1)
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		b.active.Add(-1)
+		return nil, err
+	}
+	resp.Body = &trackedBody{ReadCloser: resp.Body, done: func() { b.active.Add(-1) }}
+	return resp, nil
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// idempotent — повторять можно только запросы без тела и без побочных эффектов
+func idempotent(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// retryableStatus — ответы, после которых инстанс считаем сбойным. 504 сюда не входит: его отдаёт сам сервис
+// (db_timeout на медленный запрос), повтор только умножил бы нагрузку на БД, а breaker закрыл бы все маршруты инстанса.
+// Свой таймаут до апстрима — ошибка транспорта, он и так повторяется.
+func retryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable
+}
+
+// backoff — base*2^(attempt-1) с разбросом в половину, чтобы повторы разных клиентов не шли пачкой
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := time.Duration(float64(base) * math.Pow(2, float64(attempt-1)))
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, p *Pool, err error) {
+	status, code, msg := http.StatusBadGateway, problem.CodeUpstreamUnavailable, "upstream unavailable"
+	switch {
+	case errors.Is(err, ErrOpen):
+		status, code, msg = http.StatusServiceUnavailable, problem.CodeCircuitOpen, "upstream circuit open"
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(p.retryAfter().Seconds()))))
+	case errors.Is(err, ErrNoBackend):
+		status, code, msg = http.StatusServiceUnavailable, problem.CodeNoHealthyUpstream, "no healthy upstream instance"
+	case errors.Is(r.Context().Err(), context.Canceled):
+		// клиент ушёл, ответ никто не прочитает
+		slog.Debug("client went away", "upstream", p.name, "path", r.URL.Path)
+	case isTimeout(err):
+		status, code, msg = http.StatusGatewayTimeout, problem.CodeUpstreamTimeout, "upstream timeout"
+		fallthrough
+	default:
+		slog.Warn("upstream request failed", "upstream", p.name, "method", r.Method, "path", r.URL.Path, "status", status, "err", err)
+	}
+	body := problem.New(r, status, code, msg)
+	body["upstream"] = p.name
+	problem.Write(w, status, body)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
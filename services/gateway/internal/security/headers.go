@@ -0,0 +1,92 @@
+// Package security — стандартные защитные заголовки ответов gateway.
+package security
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCSP подходит для встроенных SPA: скрипты и стили только внешними файлами (конфиг тоже — /config.js),
+// инлайна нет, поэтому и 'unsafe-inline' не нужен; API на том же origin
+const DefaultCSP = "default-src 'self'; script-src 'self'; style-src 'self'; " +
+	"img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+
+// AddConnectSrc дописывает origins в connect-src политики: SPA с API_BASE_URL на другом origin
+// иначе не сможет сделать ни одного запроса. Нет директивы — она добавляется с 'self'
+func AddConnectSrc(csp string, origins []string) (string, error) {
+	var srcs []string
+	for _, o := range origins {
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("connect-src origin %q must be an absolute http(s) URL", o)
+		}
+		srcs = append(srcs, u.Scheme+"://"+u.Host)
+	}
+	if csp == "" || len(srcs) == 0 {
+		return csp, nil
+	}
+	directives := strings.Split(csp, ";")
+	for i, d := range directives {
+		if name, _, _ := strings.Cut(strings.TrimSpace(d), " "); name == "connect-src" {
+			directives[i] = strings.TrimRight(d, " ") + " " + strings.Join(srcs, " ")
+			return strings.Join(directives, ";"), nil
+		}
+	}
+	return csp + "; connect-src 'self' " + strings.Join(srcs, " "), nil
+}
+
+type Options struct {
+	// CSP — Content-Security-Policy; пусто — заголовок не ставится
+	CSP string
+	// HSTSMaxAge > 0 включает Strict-Transport-Security, но только на ответах по HTTPS
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+}
+
+func Headers(opts Options) func(http.Handler) http.Handler {
+	hsts := "max-age=" + strconv.Itoa(int(opts.HSTSMaxAge.Seconds()))
+	if opts.HSTSIncludeSubdomains {
+		hsts += "; includeSubDomains"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			if opts.CSP != "" {
+				h.Set("Content-Security-Policy", opts.CSP)
+			}
+			// По HTTP заголовок браузер всё равно игнорирует, а за TLS-балансером смотрим на X-Forwarded-Proto
+			if opts.HSTSMaxAge > 0 && (r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https") {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RedirectHTTPS — обработчик для HTTP-порта: всё уходит 308 на тот же путь по HTTPS.
+// port — публичный HTTPS-порт, "443" в адрес не пишется.
+func RedirectHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		switch {
+		case port != "" && port != "443":
+			host = net.JoinHostPort(host, port)
+		case strings.Contains(host, ":"):
+			host = "[" + host + "]"
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
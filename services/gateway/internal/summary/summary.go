@@ -0,0 +1,140 @@
+// Package summary — сводка пользователя для дашборда: баланс из payments и заказы из orders одним ответом.
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/webshop/gateway/internal/problem"
+	"github.com/example/webshop/gateway/internal/upstream"
+)
+
+const (
+	defaultRecent = 5
+	maxRecent     = 50
+)
+
+// Handler ходит в апстримы параллельно; клиенты — proxy.Pool.Client(), с балансировкой и breaker
+type Handler struct {
+	orders   *http.Client
+	payments *http.Client
+}
+
+func NewHandler(orders, payments *http.Client) *Handler {
+	return &Handler{orders: orders, payments: payments}
+}
+
+type Balance struct {
+	Balance int64  `json:"balance"`
+	Status  string `json:"status"`
+}
+
+type Order struct {
+	ID          int64     `json:"id"`
+	Amount      int64     `json:"amount"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	// FailureReason — почему отменена оплата, только у CANCELLED
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+type Orders struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	// PendingAmount — сумма заказов, которые ещё ждут оплаты (NEW)
+	PendingAmount int64   `json:"pending_amount"`
+	Recent        []Order `json:"recent"`
+}
+
+// Summary — раздел, который не удалось собрать, остаётся null, а причина лежит в Errors
+type Summary struct {
+	UserID  string            `json:"user_id"`
+	Balance *Balance          `json:"balance"`
+	Orders  *Orders           `json:"orders"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "user_id")
+	recent := defaultRecent
+	if v := r.URL.Query().Get("recent"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxRecent {
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeValidation, fmt.Sprintf("recent must be 0..%d", maxRecent))
+			return
+		}
+		recent = n
+	}
+
+	var (
+		wg                 sync.WaitGroup
+		balance            *Balance
+		orders             *Orders
+		balanceErr, ordErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		balance, balanceErr = h.fetchBalance(r.Context(), r, userID)
+	}()
+	go func() {
+		defer wg.Done()
+		orders, ordErr = h.fetchOrders(r.Context(), r, userID, recent)
+	}()
+	wg.Wait()
+
+	res := Summary{UserID: userID, Balance: balance, Orders: orders}
+	if balanceErr != nil || ordErr != nil {
+		res.Errors = map[string]string{}
+	}
+	if balanceErr != nil {
+		res.Errors["balance"] = balanceErr.Error()
+	}
+	if ordErr != nil {
+		res.Errors["orders"] = ordErr.Error()
+	}
+
+	status := http.StatusOK
+	// Отдать нечего — это уже не частичный ответ
+	if balanceErr != nil && ordErr != nil {
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) fetchBalance(ctx context.Context, in *http.Request, userID string) (*Balance, error) {
+	var b Balance
+	if err := upstream.GetJSON(ctx, h.payments, in, "http://payments/accounts/"+url.PathEscape(userID)+"/balance", &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// fetchOrders: счётчики считает orders (/stats), заказов тянем только recent последних — история не выгружается
+func (h *Handler) fetchOrders(ctx context.Context, in *http.Request, userID string, recent int) (*Orders, error) {
+	q := url.Values{"user_id": {userID}}
+	res := &Orders{Recent: []Order{}}
+	if err := upstream.GetJSON(ctx, h.orders, in, "http://orders/stats?"+q.Encode(), res); err != nil {
+		return nil, err
+	}
+	if recent == 0 {
+		return res, nil
+	}
+
+	q.Set("limit", strconv.Itoa(recent))
+	if err := upstream.GetJSON(ctx, h.orders, in, "http://orders/?"+q.Encode(), &res.Recent); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
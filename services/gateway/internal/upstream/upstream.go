@@ -0,0 +1,92 @@
+// Package upstream — JSON-запросы gateway к сервисам для композиций (сводка, карточка заказа бэк-офиса).
+// Клиенты — proxy.Pool.Client(), так что балансировка, повторы и breaker те же, что у прокси.
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/example/webshop/gateway/internal/problem"
+)
+
+// maxErrorBody — сколько тела ошибки читаем: его можно переслать клиенту как есть
+const maxErrorBody = 64 << 10
+
+// forwardHeaders — что берём из входящего запроса. X-Operator-ID от клиента auth.Middleware уже выкинул,
+// так что здесь он есть только на админских маршрутах, после проверки токена
+var forwardHeaders = []string{"X-Request-ID", "X-Operator-ID"}
+
+// StatusError — апстрим ответил не 200; тело (обычно problem+json) сохранено для пересылки
+type StatusError struct {
+	Host        string
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Error — из problem+json берутся detail (или title) и code, а не сырой JSON
+func (e *StatusError) Error() string {
+	if strings.HasPrefix(e.ContentType, problem.ContentType) {
+		var p struct {
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+			Code   string `json:"code"`
+		}
+		if json.Unmarshal(e.Body, &p) == nil && p.Code != "" {
+			msg := p.Detail
+			if msg == "" {
+				msg = p.Title
+			}
+			return fmt.Sprintf("%s: status %d: %s (%s)", e.Host, e.Status, msg, p.Code)
+		}
+	}
+	return fmt.Sprintf("%s: status %d: %s", e.Host, e.Status, strings.TrimSpace(string(e.Body[:min(len(e.Body), 512)])))
+}
+
+// GetJSON тянет JSON из апстрима. Сетевая ошибка — без служебного URL вида http://orders/..., не 200 — *StatusError
+func GetJSON(ctx context.Context, client *http.Client, in *http.Request, rawURL string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for _, h := range forwardHeaders {
+		if v := in.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("%s unavailable: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &StatusError{Host: req.URL.Host, Status: resp.StatusCode, ContentType: resp.Header.Get("Content-Type"), Body: body}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: bad response: %w", req.URL.Host, err)
+	}
+	return nil
+}
+
+// WriteError отдаёт ответ апстрима клиенту без изменений; сетевая ошибка — 502 upstream_unavailable
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var se *StatusError
+	if errors.As(err, &se) {
+		w.Header().Set("Content-Type", se.ContentType)
+		w.WriteHeader(se.Status)
+		_, _ = w.Write(se.Body)
+		return
+	}
+	problem.Error(w, r, http.StatusBadGateway, problem.CodeUpstreamUnavailable, err.Error())
+}
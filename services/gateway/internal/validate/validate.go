@@ -0,0 +1,183 @@
+// Package validate проверяет запросы (и в dev-режиме ответы) gateway по docs/openapi.yaml.
+package validate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	"github.com/example/webshop/gateway/internal/logging"
+	"github.com/example/webshop/gateway/internal/problem"
+)
+
+type Options struct {
+	// Responses — проверять и ответы апстримов. Ответ буферизуется целиком, поэтому только для dev:
+	// расхождения пишутся в лог и в заголовок X-Response-Validation, сам ответ не меняется.
+	Responses bool
+}
+
+type Validator struct {
+	router routers.Router
+	opts   Options
+}
+
+// Load читает спецификацию и проверяет её саму: кривой spec — ошибка на старте, а не 400 на каждый запрос
+func Load(path string, opts Options) (*Validator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load openapi spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec %s: %w", path, err)
+	}
+	// servers в спеке — для документации (localhost:8080); сверяем только путь, не хост
+	doc.Servers = openapi3.Servers{{URL: "/"}}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{router: router, opts: opts}, nil
+}
+
+// Violation — одно нарушение: где (path|query|header|body), какое поле и что не так
+type Violation struct {
+	In      string `json:"in"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Middleware пропускает запросы, которых нет в спецификации (фронт, /debug): спека описывает API, а не всё подряд
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			violations := collect(err)
+			logging.FromContext(r.Context()).Info("request rejected by openapi validation",
+				"method", r.Method, "path", r.URL.Path, "violations", len(violations))
+			body := problem.New(r, http.StatusBadRequest, problem.CodeValidation, "request does not match API spec")
+			body["violations"] = violations
+			problem.Write(w, http.StatusBadRequest, body)
+			return
+		}
+		if !v.opts.Responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.status,
+			Header:                 rec.header,
+			Body:                   readCloser{bytes.NewReader(rec.body.Bytes())},
+			Options:                &openapi3filter.Options{MultiError: true, IncludeResponseStatus: true},
+		})
+		if err != nil {
+			violations := collect(err)
+			logging.FromContext(r.Context()).Warn("response does not match openapi spec",
+				"method", r.Method, "path", r.URL.Path, "status", rec.status, "violations", violations)
+			rec.header.Set("X-Response-Validation", "failed")
+		}
+		for k, vals := range rec.header {
+			w.Header()[k] = vals
+		}
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+	})
+}
+
+// collect раскладывает дерево ошибок kin-openapi в плоский список нарушений.
+// Типы проверяем напрямую, без errors.As: он провалился бы сквозь RequestError и потерял, где ошибка.
+func collect(err error) []Violation {
+	var out []Violation
+	var walk func(in, field string, err error)
+	walk = func(in, field string, err error) {
+		switch e := err.(type) {
+		case openapi3.MultiError:
+			for _, inner := range e {
+				walk(in, field, inner)
+			}
+		case *openapi3filter.RequestError:
+			switch {
+			case e.Parameter != nil:
+				in, field = e.Parameter.In, e.Parameter.Name
+			case e.RequestBody != nil:
+				in = "body"
+			}
+			if e.Err != nil {
+				walk(in, field, e.Err)
+				return
+			}
+			out = append(out, Violation{In: in, Field: field, Message: e.Reason})
+		case *openapi3filter.ResponseError:
+			if e.Err != nil {
+				walk("response", field, e.Err)
+				return
+			}
+			out = append(out, Violation{In: "response", Message: e.Reason})
+		case *openapi3.SchemaError:
+			if ptr := e.JSONPointer(); len(ptr) > 0 {
+				field = strings.Join(ptr, ".")
+			}
+			out = append(out, Violation{In: in, Field: field, Message: e.Reason})
+		case *openapi3filter.ParseError:
+			msg := e.Reason
+			if msg == "" && e.Cause != nil {
+				msg = e.Cause.Error()
+			}
+			out = append(out, Violation{In: in, Field: field, Message: msg})
+		default:
+			out = append(out, Violation{In: in, Field: field, Message: err.Error()})
+		}
+	}
+	walk("", "", err)
+	return out
+}
+
+// recorder держит ответ целиком, чтобы проверить его до отправки клиенту
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wrote {
+		r.wrote = true
+		r.status = code
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(b)
+}
+
+type readCloser struct{ *bytes.Reader }
+
+func (readCloser) Close() error { return nil }
@@ -1,33 +1,199 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"log/slog"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/go-chi/chi/v5"
+	"github.com/example/webshop/gateway/app"
+	"github.com/example/webshop/gateway/internal/accesslog"
+	"github.com/example/webshop/gateway/internal/auth"
+	"github.com/example/webshop/gateway/internal/certs"
+	"github.com/example/webshop/gateway/internal/cors"
+	"github.com/example/webshop/gateway/internal/logging"
+	"github.com/example/webshop/gateway/internal/proxy"
+	"github.com/example/webshop/gateway/internal/security"
+	"github.com/example/webshop/gateway/internal/validate"
 )
 
 func main() {
+	logging.Setup("gateway", getenv("LOG_LEVEL", "info"), getenv("LOG_FORMAT", "json"))
 	port := getenv("PORT", "8080")
-	ordersURL := getenv("ORDERS_URL", "http://orders-service:8081")
-	paymentsURL := getenv("PAYMENTS_URL", "http://payments-service:8082")
-	frontendURL := getenv("FRONTEND_URL", "http://frontend:8083")
+	cfg := app.Config{
+		OrdersURLs:   getList("ORDERS_URL", "http://orders-service:8081"),
+		PaymentsURLs: getList("PAYMENTS_URL", "http://payments-service:8082"),
+		FrontendURLs: getList("FRONTEND_URL", "http://frontend:8083"),
+		Upstream: proxy.Options{
+			DialTimeout:           getDuration("UPSTREAM_DIAL_TIMEOUT", 2*time.Second),
+			ResponseHeaderTimeout: getDuration("UPSTREAM_RESPONSE_HEADER_TIMEOUT", 10*time.Second),
+			Timeout:               getDuration("UPSTREAM_TIMEOUT", 30*time.Second),
+			Retries:               getInt("UPSTREAM_RETRIES", 2),
+			RetryBackoff:          getDuration("UPSTREAM_RETRY_BACKOFF", 100*time.Millisecond),
+			BreakerFailures:       getInt("BREAKER_FAILURES", 5),
+			BreakerCooldown:       getDuration("BREAKER_COOLDOWN", 10*time.Second),
+			Balance:               getenv("LB_STRATEGY", proxy.BalanceRoundRobin),
+			HealthPath:            getenv("HEALTH_CHECK_PATH", "/healthz"),
+			HealthInterval:        getDuration("HEALTH_CHECK_INTERVAL", 5*time.Second),
+			HealthTimeout:         getDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		},
+		CORS: cors.Options{
+			AllowedOrigins:   getList("CORS_ALLOWED_ORIGINS", ""),
+			AllowedMethods:   getList("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS"),
+			AllowedHeaders:   getList("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,X-Request-ID"),
+			ExposedHeaders:   getList("CORS_EXPOSED_HEADERS", "X-Request-ID,Retry-After,X-Total-Count"),
+			AllowCredentials: getBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Security: security.Options{
+			CSP:                   getenv("CSP", security.DefaultCSP),
+			HSTSMaxAge:            getDuration("HSTS_MAX_AGE", 365*24*time.Hour),
+			HSTSIncludeSubdomains: getBool("HSTS_INCLUDE_SUBDOMAINS", true),
+		},
+	}
+	if cfg.Security.CSP == "off" {
+		cfg.Security.CSP = ""
+	}
+	// CSP_CONNECT_SRC — origin API, если фронт ходит в него напрямую (API_BASE_URL фронта): без этого CSP заблокирует запросы
+	csp, err := security.AddConnectSrc(cfg.Security.CSP, getList("CSP_CONNECT_SRC", ""))
+	if err != nil {
+		slog.Error("invalid CSP_CONNECT_SRC", "err", err)
+		os.Exit(1)
+	}
+	cfg.Security.CSP = csp
+
+	// ADMIN_TOKENS=alice:token1,bob:token2; без них /admin и админки сервисов закрыты
+	tokens, err := auth.ParseTokens(getList("ADMIN_TOKENS", ""))
+	if err != nil {
+		slog.Error("admin tokens", "err", err)
+		os.Exit(1)
+	}
+	cfg.AdminTokens = tokens
+	if len(tokens) == 0 {
+		slog.Warn("ADMIN_TOKENS not set, admin routes are closed")
+	}
+
+	// Спека лежит в docs/ и монтируется в контейнер; без OPENAPI_SPEC валидации нет
+	if path := os.Getenv("OPENAPI_SPEC"); path != "" {
+		v, err := validate.Load(path, validate.Options{Responses: getBool("OPENAPI_VALIDATE_RESPONSES", false)})
+		if err != nil {
+			slog.Error("openapi spec", "err", err)
+			os.Exit(1)
+		}
+		cfg.Validator = v
+	}
+
+	// Аудит тел запросов — только в файл и только для выбранных маршрутов
+	if path := os.Getenv("AUDIT_FILE"); path != "" {
+		file, err := accesslog.OpenRotating(path, int64(getInt("AUDIT_MAX_SIZE_MB", 100))<<20, getInt("AUDIT_MAX_BACKUPS", 5))
+		if err != nil {
+			slog.Error("audit file", "err", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		cfg.Audit = accesslog.NewAuditor(file, accesslog.AuditOptions{
+			Routes:        getList("AUDIT_ROUTES", "POST /orders,/payments/accounts,/payments/admin/,/admin/"),
+			SampleRate:    getFloat("AUDIT_SAMPLE_RATE", 1),
+			MaxBodyBytes:  getInt("AUDIT_MAX_BODY_BYTES", 64<<10),
+			RedactFields:  getList("AUDIT_REDACT_FIELDS", "password,token,secret,api_key,card_number,cvv"),
+			RedactHeaders: getList("AUDIT_REDACT_HEADERS", "Authorization,Cookie,Set-Cookie"),
+		})
+	}
+	if cfg.Upstream.Balance != proxy.BalanceRoundRobin && cfg.Upstream.Balance != proxy.BalanceLeastConn {
+		slog.Error("LB_STRATEGY must be round_robin or least_conn", "value", cfg.Upstream.Balance)
+		os.Exit(1)
+	}
+
+	gw, err := app.New(cfg)
+	if err != nil {
+		slog.Error("gateway router", "err", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	go gw.Run(ctx)
+
+	// Список инстансов из файла перекрывает env и перечитывается без рестарта: по mtime и по SIGHUP
+	upstreamsFile := os.Getenv("UPSTREAMS_FILE")
+	if upstreamsFile != "" {
+		if err := gw.LoadUpstreams(upstreamsFile); err != nil {
+			slog.Error("upstreams file", "err", err)
+			os.Exit(1)
+		}
+		go gw.WatchUpstreams(ctx, upstreamsFile, getPositiveDuration("UPSTREAMS_RELOAD_INTERVAL", 5*time.Second))
+	}
+
+	// HTTPS включается парой TLS_CERT_FILE/TLS_KEY_FILE; сертификат перечитывается по mtime и по SIGHUP
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		slog.Error("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		os.Exit(1)
+	}
+	var cert *certs.Reloader
+	if certFile != "" {
+		cert, err = certs.New(certFile, keyFile)
+		if err != nil {
+			slog.Error("tls certificate", "err", err)
+			os.Exit(1)
+		}
+		go cert.Watch(ctx, getPositiveDuration("TLS_RELOAD_INTERVAL", time.Minute))
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if upstreamsFile != "" {
+				if err := gw.LoadUpstreams(upstreamsFile); err != nil {
+					slog.Error("upstreams reload failed, keeping current instances", "path", upstreamsFile, "err", err)
+				} else {
+					slog.Info("upstreams reloaded", "path", upstreamsFile)
+				}
+			}
+			if cert != nil {
+				if err := cert.Reload(); err != nil {
+					slog.Error("tls cert reload failed, keeping current certificate", "err", err)
+				} else {
+					slog.Info("tls certificate reloaded", "cert", certFile)
+				}
+			}
+		}
+	}()
 
-	r := chi.NewRouter()
-	r.Mount("/orders", http.StripPrefix("/orders", newProxy(ordersURL)))
-	r.Mount("/payments", http.StripPrefix("/payments", newProxy(paymentsURL)))
-	// Всё, что не схавали выше, отдаём фронту
-	r.NotFound(func(w http.ResponseWriter, req *http.Request) {
-		newProxy(frontendURL).ServeHTTP(w, req)
-	})
+	if cert == nil {
+		slog.Info("listening", "port", port)
+		if err := newServer(port, gw.Handler()).ListenAndServe(); err != nil {
+			slog.Error("gateway server failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	log.Printf("gateway listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("gateway server: %v", err)
+	tlsPort := getenv("TLS_PORT", "8443")
+	httpsSrv := newServer(tlsPort, gw.Handler())
+	httpsSrv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: cert.GetCertificate}
+	// HTTP-порт по умолчанию только редиректит; TLS_PUBLIC_PORT — порт HTTPS снаружи (за docker/NAT)
+	httpHandler := security.RedirectHTTPS(getenv("TLS_PUBLIC_PORT", tlsPort))
+	if !getBool("HTTP_REDIRECT", true) {
+		httpHandler = gw.Handler()
 	}
+
+	errc := make(chan error, 2)
+	go func() { errc <- httpsSrv.ListenAndServeTLS("", "") }()
+	go func() { errc <- newServer(port, httpHandler).ListenAndServe() }()
+	slog.Info("listening", "port", port, "tls_port", tlsPort)
+	slog.Error("gateway server failed", "err", <-errc)
+	os.Exit(1)
+}
+
+func newServer(port string, h http.Handler) *http.Server {
+	return &http.Server{Addr: ":" + port, Handler: h, ReadHeaderTimeout: 10 * time.Second}
 }
 
 func getenv(key, fallback string) string {
@@ -37,12 +203,75 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
-func newProxy(raw string) http.Handler {
-	target, err := url.Parse(raw)
+func getDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Error("invalid duration", "key", key, "value", v)
+		os.Exit(1)
+	}
+	return d
+}
+
+// getPositiveDuration — для интервалов тикеров: 0 или минус уронили бы time.NewTicker паникой в фоновой горутине
+func getPositiveDuration(key string, fallback time.Duration) time.Duration {
+	d := getDuration(key, fallback)
+	if d <= 0 {
+		slog.Error("duration must be positive", "key", key, "value", d.String())
+		os.Exit(1)
+	}
+	return d
+}
+
+func getInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Error("invalid number", "key", key, "value", v)
+		os.Exit(1)
+	}
+	return n
+}
+
+// getList — несколько инстансов через запятую: ORDERS_URL=http://orders-1:8081,http://orders-2:8081
+func getList(key, fallback string) []string {
+	var list []string
+	for _, v := range strings.Split(getenv(key, fallback), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
 	if err != nil {
-		log.Fatalf("invalid proxy url %s: %v", raw, err)
+		slog.Error("invalid bool", "key", key, "value", v)
+		os.Exit(1)
 	}
-	proxy := httputil.NewSingleHostReverseProxy(target)
-	return proxy
+	return b
 }
 
+func getFloat(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		slog.Error("invalid number", "key", key, "value", v)
+		os.Exit(1)
+	}
+	return f
+}
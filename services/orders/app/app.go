@@ -0,0 +1,178 @@
+// Package app собирает orders-service целиком: БД, RabbitMQ-сессию, фоновые джобы и HTTP-роутер.
+// Вынесен из main, чтобы e2e-тесты могли поднять сервис в своём процессе.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/example/webshop/orders/internal/config"
+	"github.com/example/webshop/orders/internal/db"
+	"github.com/example/webshop/orders/internal/deadletter"
+	httpapi "github.com/example/webshop/orders/internal/http"
+	"github.com/example/webshop/orders/internal/janitor"
+	"github.com/example/webshop/orders/internal/logging"
+	"github.com/example/webshop/orders/internal/mq"
+	"github.com/example/webshop/orders/internal/order"
+	"github.com/example/webshop/orders/internal/outbox"
+)
+
+// Config — алиас внутреннего конфига, чтобы его можно было собрать снаружи модуля
+type Config = config.Config
+
+// LoadConfig читает CONFIG_FILE и env и валидирует результат
+func LoadConfig() (Config, error) {
+	return config.Load()
+}
+
+// DefaultConfig — дефолты без файла и env; адреса БД и брокера надо задать самому
+func DefaultConfig() Config {
+	return config.Default()
+}
+
+type App struct {
+	cfg     Config
+	db      *sql.DB
+	svc     *order.Service
+	outbox  *outbox.Repository
+	dead    *deadletter.Repository
+	topo    mq.Topology
+	session *mq.Session
+	cleaner *janitor.Janitor
+	router  *chi.Mux
+}
+
+func New(ctx context.Context, cfg Config) (*App, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	dbConn, err := sql.Open("pgx", cfg.DBURL)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	dbConn.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	dbConn.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	dbConn.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	dbConn.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	if err := waitForDB(ctx, dbConn, cfg.DBConnectTimeout); err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("db not ready: %w", err)
+	}
+	if err := db.Migrate(ctx, dbConn); err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("db migrate: %w", err)
+	}
+
+	orderRepo := order.NewRepository(dbConn)
+	outboxRepo := outbox.NewRepository(dbConn)
+	deadRepo := deadletter.NewRepository(dbConn)
+	svc := order.NewService(dbConn, orderRepo, outboxRepo)
+	topo := mq.Topology{OrderPayments: cfg.QueueOrderPayments, PaymentStatus: cfg.QueuePaymentStatus}
+	session := mq.NewSession(cfg.RabbitURL, cfg.RabbitReconnectDelay, topo.Declare)
+
+	cleaner := janitor.New(outboxRepo, janitor.Options{
+		OutboxRetention: cfg.OutboxRetention,
+		Interval:        cfg.JanitorInterval,
+		BatchSize:       cfg.JanitorBatchSize,
+		Archive:         cfg.OutboxArchive,
+	})
+
+	handler := httpapi.NewHandler(svc)
+	r := chi.NewRouter()
+	r.Use(logging.Middleware)
+	r.NotFound(httpapi.NotFound)
+	r.MethodNotAllowed(httpapi.MethodNotAllowed)
+	r.Handle("/debug/vars", expvar.Handler())
+	r.Get("/debug/db", httpapi.DBStats(dbConn))
+	r.Get("/healthz", httpapi.Healthz(dbConn))
+	r.Mount("/admin/replay", httpapi.NewReplayHandler(outboxRepo, deadRepo, session, topo).Router())
+	r.With(httpapi.RequestTimeout(cfg.HTTPRequestTimeout)).Mount("/admin/orders", httpapi.NewAdminHandler(svc, outboxRepo).Router())
+	r.With(httpapi.RequestTimeout(cfg.HTTPRequestTimeout)).Mount("/", handler.Router())
+
+	return &App{
+		cfg:     cfg,
+		db:      dbConn,
+		svc:     svc,
+		outbox:  outboxRepo,
+		dead:    deadRepo,
+		topo:    topo,
+		session: session,
+		cleaner: cleaner,
+		router:  r,
+	}, nil
+}
+
+func (a *App) Handler() http.Handler {
+	return a.router
+}
+
+// Run крутит фоновые джобы до отмены ctx; RabbitMQ переподключается сам
+func (a *App) Run(ctx context.Context) {
+	go a.cleaner.Run(ctx)
+	a.session.Run(ctx, a.startMessaging)
+}
+
+func (a *App) Close() error {
+	return a.db.Close()
+}
+
+// startMessaging запускает паблишер и консьюмер на канале сессии; умер консьюмер — гасим и паблишер,
+// чтобы сессия пересоздалась целиком
+func (a *App) startMessaging(ctx context.Context, ch *amqp.Channel) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outboxPub := mq.NewOutboxPublisher(a.db, a.outbox, ch, a.cfg.DBURL, a.cfg.OutboxInterval, a.cfg.OutboxBatchSize, a.topo.OrderPayments)
+	statusConsumer := mq.NewPaymentStatusConsumer(a.svc, a.dead, ch, a.consumerOptions(a.topo.PaymentStatus))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		outboxPub.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		if err := statusConsumer.Run(ctx); err != nil {
+			slog.Error("payment status consumer stopped", "err", err)
+		}
+	}()
+	wg.Wait()
+}
+
+func (a *App) consumerOptions(queue string) mq.ConsumerOptions {
+	return mq.ConsumerOptions{
+		Queue:    queue,
+		Prefetch: a.cfg.ConsumerPrefetch,
+		Workers:  a.cfg.ConsumerWorkers,
+		Retry: mq.RetryPolicy{
+			MaxAttempts:    a.cfg.RetryMaxAttempts,
+			InitialBackoff: a.cfg.RetryInitialBackoff,
+			MaxBackoff:     a.cfg.RetryMaxBackoff,
+		},
+	}
+}
+
+func waitForDB(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return context.DeadlineExceeded
+		}
+		time.Sleep(time.Second)
+	}
+}
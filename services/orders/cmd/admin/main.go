@@ -0,0 +1,156 @@
+// Команда admin — replay-инструменты orders: переопубликовать outbox, выгрузить outbox/inbox,
+// влить JSONL с сообщениями в очередь. Берёт DATABASE_URL и RABBIT_URL из того же env, что и сервис.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/example/webshop/orders/internal/config"
+	"github.com/example/webshop/orders/internal/mq"
+	"github.com/example/webshop/orders/internal/outbox"
+)
+
+const usage = `usage: admin <command> [flags]
+
+commands:
+  requeue      clear published_at so the publisher sends rows again (-id, -order, -from, -to)
+  dump-outbox  print outbox rows as JSON lines (-id, -order, -from, -to)
+  inject       publish JSON lines into a queue (-queue, -file or stdin)
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	switch cmd {
+	case "requeue":
+		err = requeue(ctx, cfg, args)
+	case "dump-outbox":
+		err = dumpOutbox(ctx, cfg, args)
+	case "inject":
+		err = inject(ctx, cfg, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", cmd, err)
+	}
+}
+
+func requeue(ctx context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("requeue", flag.ExitOnError)
+	f := filterFlags(fs)
+	_ = fs.Parse(args)
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	n, err := outbox.NewRepository(db).Requeue(ctx, *f)
+	if err != nil {
+		return err
+	}
+	log.Printf("requeued %d outbox rows", n)
+	return nil
+}
+
+func dumpOutbox(ctx context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("dump-outbox", flag.ExitOnError)
+	f := filterFlags(fs)
+	_ = fs.Parse(args)
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	return outbox.NewRepository(db).Dump(ctx, *f, func(rec outbox.Record) error { return enc.Encode(rec) })
+}
+
+func inject(ctx context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("inject", flag.ExitOnError)
+	queue := fs.String("queue", "", "target queue (queue_order_payments or queue_payment_status from config)")
+	file := fs.String("file", "", "JSONL file (stdin if empty)")
+	_ = fs.Parse(args)
+
+	var in io.Reader = os.Stdin
+	if *file != "" {
+		fh, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		in = fh
+	}
+
+	conn, err := amqp.Dial(cfg.RabbitURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	n, err := mq.Inject(ctx, ch, mq.Topology{OrderPayments: cfg.QueueOrderPayments, PaymentStatus: cfg.QueuePaymentStatus}, *queue, in)
+	log.Printf("published %d messages to %s", n, *queue)
+	return err
+}
+
+func openDB(cfg config.Config) (*sql.DB, error) {
+	return sql.Open("pgx", cfg.DBURL)
+}
+
+// filterFlags вешает общие флаги фильтра outbox на набор
+func filterFlags(fs *flag.FlagSet) *outbox.Filter {
+	f := &outbox.Filter{}
+	fs.Func("id", "outbox row id (repeatable)", func(v string) error {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return err
+		}
+		f.IDs = append(f.IDs, id)
+		return nil
+	})
+	fs.Int64Var(&f.OrderID, "order", 0, "order id")
+	fs.Func("from", "created_at >= (RFC3339)", func(v string) (err error) {
+		f.From, err = time.Parse(time.RFC3339, v)
+		return err
+	})
+	fs.Func("to", "created_at < (RFC3339)", func(v string) (err error) {
+		f.To, err = time.Parse(time.RFC3339, v)
+		return err
+	})
+	return f
+}
@@ -16,6 +16,22 @@ CREATE TABLE IF NOT EXISTS orders (
 	status TEXT NOT NULL,
 	created_at TIMESTAMP NOT NULL DEFAULT now()
 );
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS failure_code TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS orders_user_created_idx ON orders(user_id, created_at);
+CREATE INDEX IF NOT EXISTS orders_status_created_idx ON orders(status, created_at);
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id BIGSERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id),
+	from_status TEXT NOT NULL,
+	to_status TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	operator_id TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS order_status_history_order_idx ON order_status_history(order_id, created_at);
 
 CREATE TABLE IF NOT EXISTS outbox (
 	id UUID PRIMARY KEY,
@@ -27,8 +43,32 @@ CREATE TABLE IF NOT EXISTS outbox (
 	created_at TIMESTAMP NOT NULL DEFAULT now()
 );
 CREATE INDEX IF NOT EXISTS outbox_published_idx ON outbox(published_at, created_at);
+
+CREATE TABLE IF NOT EXISTS outbox_archive (
+	id UUID PRIMARY KEY,
+	order_id INT NOT NULL,
+	user_id TEXT NOT NULL,
+	amount BIGINT NOT NULL,
+	payload JSONB NOT NULL,
+	published_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL,
+	archived_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id BIGSERIAL PRIMARY KEY,
+	queue TEXT NOT NULL,
+	message_id TEXT,
+	correlation_id TEXT,
+	routing_key TEXT,
+	body BYTEA NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	redriven_at TIMESTAMP,
+	redriven_by TEXT
+);
+CREATE INDEX IF NOT EXISTS dead_letters_pending_idx ON dead_letters(created_at) WHERE redriven_at IS NULL;
 `
 	_, err := db.ExecContext(ctx, schema)
 	return err
 }
-
@@ -0,0 +1,125 @@
+// Package deadletter — сообщения, которые консьюмер не смог разобрать (битый JSON, неизвестная версия события)
+// или обработать за все попытки RetryPolicy. Лежат в dead_letters до ручного redrive из бэк-офиса.
+package deadletter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound        = errors.New("dead letter not found")
+	ErrAlreadyRedriven = errors.New("dead letter already redriven")
+)
+
+// Letter — отложенное сообщение; Body хранится как пришло, поэтому строкой, а не JSON
+type Letter struct {
+	ID            int64      `json:"id"`
+	Queue         string     `json:"queue"`
+	MessageID     string     `json:"message_id,omitempty"`
+	CorrelationID string     `json:"correlation_id,omitempty"`
+	RoutingKey    string     `json:"routing_key,omitempty"`
+	Body          string     `json:"body"`
+	Error         string     `json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RedrivenAt    *time.Time `json:"redriven_at,omitempty"`
+	RedrivenBy    string     `json:"redriven_by,omitempty"`
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Insert(ctx context.Context, l Letter) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO dead_letters(queue, message_id, correlation_id, routing_key, body, error)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+	`, l.Queue, l.MessageID, l.CorrelationID, l.RoutingKey, []byte(l.Body), l.Error)
+	return err
+}
+
+const columns = `id, queue, COALESCE(message_id, ''), COALESCE(correlation_id, ''), COALESCE(routing_key, ''),
+		body, error, created_at, redriven_at, COALESCE(redriven_by, '')`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(s scanner) (Letter, error) {
+	var l Letter
+	var body []byte
+	err := s.Scan(&l.ID, &l.Queue, &l.MessageID, &l.CorrelationID, &l.RoutingKey, &body, &l.Error, &l.CreatedAt, &l.RedrivenAt, &l.RedrivenBy)
+	l.Body = string(body)
+	return l, err
+}
+
+// List — свежие сверху; all=false — только ещё не отправленные заново
+func (r *Repository) List(ctx context.Context, all bool, limit int) ([]Letter, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+columns+`
+		FROM dead_letters
+		WHERE $1 OR redriven_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, all, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []Letter{}
+	for rows.Next() {
+		l, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+// Pending — сколько сообщений ждут разбора
+func (r *Repository) Pending(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.db.QueryRowContext(ctx, `SELECT count(*) FROM dead_letters WHERE redriven_at IS NULL`).Scan(&n)
+	return n, err
+}
+
+// Redrive отправляет сообщение заново через publish и помечает его под FOR UPDATE:
+// два оператора одновременно одно сообщение дважды не отправят. Упал publish — пометка откатывается.
+func (r *Repository) Redrive(ctx context.Context, id int64, operatorID string, publish func(Letter) error) (Letter, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return Letter{}, err
+	}
+	defer tx.Rollback()
+
+	l, err := scan(tx.QueryRowContext(ctx, `SELECT `+columns+` FROM dead_letters WHERE id=$1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Letter{}, ErrNotFound
+	}
+	if err != nil {
+		return Letter{}, err
+	}
+	if l.RedrivenAt != nil {
+		return Letter{}, ErrAlreadyRedriven
+	}
+	if err := publish(l); err != nil {
+		return Letter{}, err
+	}
+	err = tx.QueryRowContext(ctx, `
+		UPDATE dead_letters SET redriven_at = now(), redriven_by = $1 WHERE id = $2
+		RETURNING redriven_at
+	`, operatorID, id).Scan(&l.RedrivenAt)
+	if err != nil {
+		return Letter{}, err
+	}
+	l.RedrivenBy = operatorID
+	return l, tx.Commit()
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/webshop/orders/internal/logging"
+	"github.com/example/webshop/orders/internal/order"
+	"github.com/example/webshop/orders/internal/outbox"
+	"github.com/go-chi/chi/v5"
+)
+
+// OperatorHeader — кто из поддержки выполняет действие. Ставит gateway после проверки токена,
+// сам сервис токены не проверяет.
+const OperatorHeader = "X-Operator-ID"
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+	// maxPageLimit — размер страницы истории заказов в GET /orders
+	maxPageLimit = 100
+)
+
+// AdminHandler — бэк-офис поддержки: поиск заказов, карточка заказа, ручная смена статуса
+type AdminHandler struct {
+	svc    *order.Service
+	outbox *outbox.Repository
+}
+
+func NewAdminHandler(svc *order.Service, outboxRepo *outbox.Repository) *AdminHandler {
+	return &AdminHandler{svc: svc, outbox: outboxRepo}
+}
+
+func (h *AdminHandler) Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", h.search)
+	r.Get("/{id}", h.get)
+	r.Post("/{id}/status", h.forceStatus)
+	return r
+}
+
+// search: ?user_id=&status=&from=RFC3339&to=RFC3339&limit=
+func (h *AdminHandler) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := order.SearchFilter{UserID: q.Get("user_id"), Status: strings.ToUpper(q.Get("status")), Limit: defaultSearchLimit}
+	var err error
+	if f.From, err = parseTime(q.Get("from")); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "from must be RFC3339")
+		return
+	}
+	if f.To, err = parseTime(q.Get("to")); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "to must be RFC3339")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidation, "limit must be 1.."+strconv.Itoa(maxSearchLimit))
+			return
+		}
+		f.Limit = n
+	}
+
+	items, err := h.svc.Search(r.Context(), f)
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// get — заказ, история ручных смен статуса и его строки outbox (ID строки = message_id события)
+func (h *AdminHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "id must be an integer")
+		return
+	}
+	o, err := h.svc.GetOrder(r.Context(), id)
+	if errors.Is(err, order.ErrNotFound) {
+		writeProblem(w, r, http.StatusNotFound, CodeOrderNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	history, err := h.svc.StatusHistory(r.Context(), id)
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	records := []outbox.Record{}
+	err = h.outbox.Dump(r.Context(), outbox.Filter{OrderID: id}, func(rec outbox.Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"order":          o,
+		"status_history": history,
+		"outbox":         records,
+	})
+}
+
+func (h *AdminHandler) forceStatus(w http.ResponseWriter, r *http.Request) {
+	operator := r.Header.Get(OperatorHeader)
+	if operator == "" {
+		writeProblem(w, r, http.StatusUnauthorized, CodeOperatorRequired, OperatorHeader+" header required")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "id must be an integer")
+		return
+	}
+	type req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	var body req
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+		return
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "reason required")
+		return
+	}
+
+	o, err := h.svc.ForceStatus(r.Context(), id, strings.ToUpper(body.Status), body.Reason, operator)
+	switch {
+	case errors.Is(err, order.ErrInvalidStatus):
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "status must be NEW, FINISHED or CANCELLED")
+		return
+	case errors.Is(err, order.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeOrderNotFound, err.Error())
+		return
+	case errors.Is(err, order.ErrStatusUnchanged):
+		writeProblem(w, r, http.StatusConflict, CodeStatusUnchanged, err.Error())
+		return
+	case err != nil:
+		dbError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("order status forced",
+		"order_id", id, "status", o.Status, "operator_id", operator)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(o)
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -21,6 +22,7 @@ func (h *Handler) Router() *chi.Mux {
 	r := chi.NewRouter()
 	r.Post("/", h.createOrder)
 	r.Get("/", h.listOrders)
+	r.Get("/stats", h.stats)
 	r.Get("/{id}", h.getOrder)
 	return r
 }
@@ -33,17 +35,17 @@ func (h *Handler) createOrder(w http.ResponseWriter, r *http.Request) {
 	}
 	var body req
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
 		return
 	}
 	if body.UserID == "" || body.Amount <= 0 {
-		http.Error(w, "user_id and positive amount required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "user_id and positive amount required")
 		return
 	}
 
 	order, _, _, err := h.svc.CreateOrder(r.Context(), body.UserID, body.Amount, body.Description)
 	if err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
+		dbError(w, r, err)
 		return
 	}
 
@@ -57,27 +59,72 @@ func (h *Handler) createOrder(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// listOrders: без limit — весь список, как раньше; с limit — страница, общее число в X-Total-Count
 func (h *Handler) listOrders(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("user_id")
-	items, err := h.svc.ListOrders(r.Context(), userID)
+	q := r.URL.Query()
+	userID := q.Get("user_id")
+	if q.Get("limit") == "" {
+		items, err := h.svc.ListOrders(r.Context(), userID)
+		if err != nil {
+			dbError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "limit must be 1.."+strconv.Itoa(maxPageLimit))
+		return
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidation, "offset must be a non-negative integer")
+			return
+		}
+	}
+	items, total, err := h.svc.ListOrdersPage(r.Context(), userID, limit, offset)
 	if err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
+		dbError(w, r, err)
 		return
 	}
+	if items == nil {
+		items = []order.Order{}
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	_ = json.NewEncoder(w).Encode(items)
 }
 
+// stats — счётчики по статусам для сводки gateway; user_id пустой — по всем заказам
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	st, err := h.svc.Stats(r.Context(), r.URL.Query().Get("user_id"))
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
+
 func (h *Handler) getOrder(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "id must be an integer")
 		return
 	}
 	o, err := h.svc.GetOrder(r.Context(), id)
+	if errors.Is(err, order.ErrNotFound) {
+		writeProblem(w, r, http.StatusNotFound, CodeOrderNotFound, err.Error())
+		return
+	}
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		dbError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
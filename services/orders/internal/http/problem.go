@@ -0,0 +1,65 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/example/webshop/orders/internal/logging"
+)
+
+const problemContentType = "application/problem+json"
+
+// Коды ошибок — стабильная часть ответа: клиенты ветвятся по code, текст detail может меняться
+const (
+	CodeInvalidJSON        = "invalid_json"
+	CodeValidation         = "validation_failed"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeOrderNotFound      = "order_not_found"
+	CodeStatusUnchanged    = "status_unchanged"
+	CodeOperatorRequired   = "operator_required"
+	CodeDeadLetterNotFound = "dead_letter_not_found"
+	CodeAlreadyRedriven    = "already_redriven"
+	CodeDBTimeout          = "db_timeout"
+	CodeDBUnavailable      = "db_unavailable"
+	CodeBrokerUnavailable  = "broker_unavailable"
+	CodeInvalidMessage     = "invalid_message"
+	CodeInternal           = "internal_error"
+)
+
+// problem — тело ошибки по RFC 7807. type строится из code, title — стандартный текст статуса.
+func problem(r *http.Request, status int, code, detail string) map[string]any {
+	p := map[string]any{
+		"type":     "urn:webshop:problem:" + code,
+		"title":    http.StatusText(status),
+		"status":   status,
+		"code":     code,
+		"instance": r.URL.Path,
+	}
+	if detail != "" {
+		p["detail"] = detail
+	}
+	if id := logging.RequestID(r.Context()); id != "" {
+		p["request_id"] = id
+	}
+	return p
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	sendProblem(w, status, problem(r, status, code, detail))
+}
+
+func sendProblem(w http.ResponseWriter, status int, p map[string]any) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// NotFound и MethodNotAllowed заменяют текстовые ответы chi; вешать на корневой роутер до Mount
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, CodeNotFound, "no such endpoint")
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not supported here")
+}
@@ -0,0 +1,220 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/example/webshop/orders/internal/deadletter"
+	"github.com/example/webshop/orders/internal/logging"
+	"github.com/example/webshop/orders/internal/mq"
+	"github.com/example/webshop/orders/internal/outbox"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ReplayHandler — админка для повторной отправки событий после починки консьюмера
+type ReplayHandler struct {
+	outbox  *outbox.Repository
+	dead    *deadletter.Repository
+	session *mq.Session
+	topo    mq.Topology
+}
+
+func NewReplayHandler(outboxRepo *outbox.Repository, dead *deadletter.Repository, session *mq.Session, topo mq.Topology) *ReplayHandler {
+	return &ReplayHandler{outbox: outboxRepo, dead: dead, session: session, topo: topo}
+}
+
+func (h *ReplayHandler) Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/backlog", h.backlog)
+	r.Post("/outbox/requeue", h.requeue)
+	r.Get("/outbox/export", h.exportOutbox)
+	r.Post("/inject", h.inject)
+	r.Get("/dead-letters", h.listDeadLetters)
+	r.Post("/dead-letters/{id}/redrive", h.redrive)
+	return r
+}
+
+// backlog — что застряло: неопубликованный outbox и неразобранные dead letters
+func (h *ReplayHandler) backlog(w http.ResponseWriter, r *http.Request) {
+	b, err := h.outbox.Backlog(r.Context())
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	dead, err := h.dead.Pending(r.Context())
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"outbox":       b,
+		"dead_letters": map[string]int64{"pending": dead},
+	})
+}
+
+// listDeadLetters: ?all=true — вместе с уже отправленными заново; ?limit=
+func (h *ReplayHandler) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidation, "limit must be 1.."+strconv.Itoa(maxSearchLimit))
+			return
+		}
+		limit = n
+	}
+	items, err := h.dead.List(r.Context(), r.URL.Query().Get("all") == "true", limit)
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// redrive — вернуть сообщение в очередь после починки консьюмера; не разберётся и теперь — ляжет новой записью
+func (h *ReplayHandler) redrive(w http.ResponseWriter, r *http.Request) {
+	operator := r.Header.Get(OperatorHeader)
+	if operator == "" {
+		writeProblem(w, r, http.StatusUnauthorized, CodeOperatorRequired, OperatorHeader+" header required")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "id must be an integer")
+		return
+	}
+	ch, err := h.session.Channel()
+	if err != nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeBrokerUnavailable, err.Error())
+		return
+	}
+	var pubErr error
+	l, err := h.dead.Redrive(r.Context(), id, operator, func(l deadletter.Letter) error {
+		pubErr = mq.PublishDeadLetter(r.Context(), ch, h.topo, l)
+		return pubErr
+	})
+	switch {
+	case pubErr != nil:
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeBrokerUnavailable, pubErr.Error())
+		return
+	case errors.Is(err, deadletter.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeDeadLetterNotFound, err.Error())
+		return
+	case errors.Is(err, deadletter.ErrAlreadyRedriven):
+		writeProblem(w, r, http.StatusConflict, CodeAlreadyRedriven, err.Error())
+		return
+	case err != nil:
+		dbError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("dead letter redriven",
+		"dead_letter_id", id, "queue", l.Queue, "message_id", l.MessageID, "operator_id", operator)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l)
+}
+
+func (h *ReplayHandler) requeue(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		IDs     []uuid.UUID `json:"ids"`
+		OrderID int64       `json:"order_id"`
+		From    *time.Time  `json:"from"`
+		To      *time.Time  `json:"to"`
+	}
+	var body req
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+		return
+	}
+	f := outbox.Filter{IDs: body.IDs, OrderID: body.OrderID}
+	if body.From != nil {
+		f.From = *body.From
+	}
+	if body.To != nil {
+		f.To = *body.To
+	}
+	n, err := h.outbox.Requeue(r.Context(), f)
+	if errors.Is(err, outbox.ErrEmptyFilter) {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "ids, order_id or time range required")
+		return
+	}
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"requeued": n})
+}
+
+func (h *ReplayHandler) exportOutbox(w http.ResponseWriter, r *http.Request) {
+	f, err := filterFromQuery(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	if err := h.outbox.Dump(r.Context(), f, func(rec outbox.Record) error { return enc.Encode(rec) }); err != nil {
+		// Заголовки уже ушли, остаётся только оборвать поток
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (h *ReplayHandler) inject(w http.ResponseWriter, r *http.Request) {
+	ch, err := h.session.Channel()
+	if err != nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeBrokerUnavailable, err.Error())
+		return
+	}
+	queue := r.URL.Query().Get("queue")
+	n, err := mq.Inject(r.Context(), ch, h.topo, queue, r.Body)
+	if err != nil {
+		// published — сколько сообщений до ошибки всё-таки ушло
+		p := problem(r, http.StatusBadRequest, CodeInvalidMessage, err.Error())
+		p["published"] = n
+		sendProblem(w, http.StatusBadRequest, p)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"published": n})
+}
+
+// filterFromQuery: ?id=...&id=...&order_id=...&from=RFC3339&to=RFC3339
+func filterFromQuery(r *http.Request) (outbox.Filter, error) {
+	q := r.URL.Query()
+	var f outbox.Filter
+	for _, raw := range q["id"] {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return f, errors.New("invalid id")
+		}
+		f.IDs = append(f.IDs, id)
+	}
+	if v := q.Get("order_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid order_id")
+		}
+		f.OrderID = id
+	}
+	var err error
+	if f.From, err = parseTime(q.Get("from")); err != nil {
+		return f, errors.New("invalid from")
+	}
+	if f.To, err = parseTime(q.Get("to")); err != nil {
+		return f, errors.New("invalid to")
+	}
+	return f, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,86 @@
+package janitor
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"time"
+
+	"github.com/example/webshop/orders/internal/outbox"
+)
+
+// Счётчики видны на /debug/vars
+var (
+	outboxDeleted  = expvar.NewInt("orders_janitor_outbox_deleted_total")
+	outboxArchived = expvar.NewInt("orders_janitor_outbox_archived_total")
+)
+
+type Options struct {
+	OutboxRetention time.Duration
+	Interval        time.Duration
+	BatchSize       int
+	Archive         bool
+}
+
+// Janitor периодически подчищает опубликованный outbox маленькими пачками,
+// чтобы не держать долгие локи и не раздувать FetchPending
+type Janitor struct {
+	outbox *outbox.Repository
+	opts   Options
+}
+
+func New(outboxRepo *outbox.Repository, opts Options) *Janitor {
+	return &Janitor{outbox: outboxRepo, opts: opts}
+}
+
+func (j *Janitor) Run(ctx context.Context) {
+	if j.opts.Interval <= 0 || j.opts.BatchSize <= 0 || j.opts.OutboxRetention <= 0 {
+		slog.Info("janitor disabled")
+		return
+	}
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.sweep(ctx)
+		}
+	}
+}
+
+func (j *Janitor) sweep(ctx context.Context) {
+	purge := j.outbox.DeletePublished
+	counter := outboxDeleted
+	if j.opts.Archive {
+		purge = j.outbox.ArchivePublished
+		counter = outboxArchived
+	}
+	n, err := drain(ctx, j.opts.BatchSize, func(ctx context.Context) (int64, error) {
+		return purge(ctx, j.opts.OutboxRetention, j.opts.BatchSize)
+	})
+	counter.Add(n)
+	if err != nil {
+		slog.Error("janitor outbox sweep failed", "err", err)
+	}
+	if n > 0 {
+		slog.Info("janitor outbox swept", "rows", n, "archive", j.opts.Archive)
+	}
+}
+
+// drain крутит пачки, пока они приходят полными
+func drain(ctx context.Context, batch int, step func(context.Context) (int64, error)) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := step(ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(batch) {
+			break
+		}
+	}
+	return total, nil
+}
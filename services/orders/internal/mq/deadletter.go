@@ -0,0 +1,60 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/example/webshop/orders/internal/deadletter"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// deadLetter откладывает неразбираемое сообщение в dead_letters и снимает его с очереди.
+// Записать не вышло — возвращаем в очередь: потерять сообщение хуже, чем получить его ещё раз
+func deadLetter(ctx context.Context, repo *deadletter.Repository, queue string, d amqp.Delivery, reason error) {
+	logger := slog.With("queue", queue, "message_id", d.MessageId, "correlation_id", d.CorrelationId, "routing_key", d.RoutingKey)
+	err := repo.Insert(ctx, deadletter.Letter{
+		Queue:         queue,
+		MessageID:     d.MessageId,
+		CorrelationID: d.CorrelationId,
+		RoutingKey:    d.RoutingKey,
+		Body:          string(d.Body),
+		Error:         reason.Error(),
+	})
+	if err != nil {
+		logger.Error("dead letter not stored, requeue", "reason", reason, "err", err)
+		_ = d.Nack(false, true)
+		return
+	}
+	logger.Warn("message dead-lettered", "err", reason)
+	_ = d.Ack(false)
+}
+
+// retryExhausted — повторы на месте кончились. Вернуть в очередь — получить то же сообщение сразу же и
+// навсегда занять воркер его ключа, поэтому оно уходит в dead_letters до разбора и redrive.
+// Остановка сервиса (ctx отменён) — не сбой сообщения: его дообработает следующий консьюмер
+func retryExhausted(ctx context.Context, logger *slog.Logger, repo *deadletter.Repository, queue string, d amqp.Delivery, attempts int, err error) {
+	if ctx.Err() != nil {
+		logger.Warn("handler interrupted by shutdown, requeue", "attempts", attempts, "err", err)
+		_ = d.Nack(false, true)
+		return
+	}
+	logger.Error("handler failed, retries exhausted", "attempts", attempts, "err", err)
+	deadLetter(context.WithoutCancel(ctx), repo, queue, d, fmt.Errorf("failed after %d attempts: %w", attempts, err))
+}
+
+// PublishDeadLetter возвращает отложенное сообщение в его очередь через default exchange с исходным MessageId:
+// повторный результат оплаты ничего не сломает: статус заказа меняется только из NEW (или FINISHED для возврата)
+func PublishDeadLetter(ctx context.Context, ch *amqp.Channel, topo Topology, l deadletter.Letter) error {
+	if !slices.Contains(topo.Queues(), l.Queue) {
+		return fmt.Errorf("unknown queue %q", l.Queue)
+	}
+	return ch.PublishWithContext(ctx, "", l.Queue, false, false, amqp.Publishing{
+		ContentType:   "application/json",
+		Body:          []byte(l.Body),
+		MessageId:     l.MessageID,
+		CorrelationId: l.CorrelationID,
+		DeliveryMode:  amqp.Persistent,
+	})
+}
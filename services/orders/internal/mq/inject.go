@@ -0,0 +1,67 @@
+package mq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/example/webshop/orders/internal/events"
+	"github.com/example/webshop/orders/internal/outbox"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Inject публикует JSONL прямо в очередь (default exchange). Строка — либо запись из выгрузки
+// outbox ({"id", "payload", ...}), либо голый конверт. MessageId остаётся исходным,
+// поэтому inbox-дедуп на стороне payments отсекает уже обработанные сообщения.
+// Очередь должна быть из топологии: default exchange молча выбрасывает сообщение
+// в несуществующую очередь, так что опечатку лучше поймать заранее.
+func Inject(ctx context.Context, ch *amqp.Channel, topo Topology, queue string, r io.Reader) (int, error) {
+	if !slices.Contains(topo.Queues(), queue) {
+		return 0, fmt.Errorf("unknown queue %q", queue)
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	published := 0
+	for line := 1; sc.Scan(); line++ {
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		msg, err := parseInjectLine(raw)
+		if err != nil {
+			return published, fmt.Errorf("line %d: %w", line, err)
+		}
+		_, _, pub := publishing(msg, queue)
+		if err := ch.PublishWithContext(ctx, "", queue, false, false, pub); err != nil {
+			return published, fmt.Errorf("line %d: %w", line, err)
+		}
+		published++
+	}
+	return published, sc.Err()
+}
+
+func parseInjectLine(raw []byte) (outbox.Message, error) {
+	var rec outbox.Record
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return outbox.Message{}, err
+	}
+	if len(rec.Payload) > 0 {
+		return outbox.Message{ID: rec.ID, OrderID: rec.OrderID, Payload: rec.Payload, CreatedAt: rec.CreatedAt}, nil
+	}
+
+	env, err := events.Decode(raw)
+	if err != nil {
+		return outbox.Message{}, err
+	}
+	id, err := uuid.Parse(env.ID)
+	if err != nil {
+		return outbox.Message{}, fmt.Errorf("envelope id: %w", err)
+	}
+	return outbox.Message{ID: id, Payload: append([]byte(nil), raw...), CreatedAt: env.Timestamp}, nil
+}
@@ -3,55 +3,123 @@ package mq
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
 
+	"github.com/example/webshop/orders/internal/deadletter"
+	"github.com/example/webshop/orders/internal/events"
 	"github.com/example/webshop/orders/internal/order"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-type PaymentResult struct {
-	OrderID int64  `json:"order_id"`
-	Status  string `json:"status"`
-	Reason  string `json:"reason,omitempty"`
+// ConsumerOptions — очередь, prefetch на канал, размер пула воркеров и повторы обработки
+type ConsumerOptions struct {
+	Queue    string
+	Prefetch int
+	Workers  int
+	Retry    RetryPolicy
 }
 
 type PaymentStatusConsumer struct {
 	svc     *order.Service
+	dead    *deadletter.Repository
 	channel *amqp.Channel
+	opts    ConsumerOptions
 }
 
-func NewPaymentStatusConsumer(svc *order.Service, ch *amqp.Channel) *PaymentStatusConsumer {
-	return &PaymentStatusConsumer{svc: svc, channel: ch}
+func NewPaymentStatusConsumer(svc *order.Service, dead *deadletter.Repository, ch *amqp.Channel, opts ConsumerOptions) *PaymentStatusConsumer {
+	return &PaymentStatusConsumer{svc: svc, dead: dead, channel: ch, opts: opts}
+}
+
+type resultJob struct {
+	delivery amqp.Delivery
+	result   events.PaymentResultV1
 }
 
 func (c *PaymentStatusConsumer) Run(ctx context.Context) error {
-	msgs, err := c.channel.Consume("payment.status", "orders-status", false, false, false, false, nil)
+	if err := c.channel.Qos(c.opts.Prefetch, 0, false); err != nil {
+		return err
+	}
+	msgs, err := c.channel.Consume(c.opts.Queue, "orders-status", false, false, false, false, nil)
 	if err != nil {
 		return err
 	}
 
+	pool := newKeyedPool(c.opts.Workers, c.opts.Prefetch, func(j resultJob) { c.handle(ctx, j) })
+	defer pool.Close()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case d, ok := <-msgs:
 			if !ok {
-				log.Printf("payment.status channel closed")
+				slog.Warn("consumer channel closed", "queue", c.opts.Queue)
 				return nil
 			}
-			var res PaymentResult
-			if err := json.Unmarshal(d.Body, &res); err != nil {
-				log.Printf("bad payment result: %v", err)
-				_ = d.Nack(false, false)
+			res, err := decodePaymentResult(d.Body)
+			if err != nil {
+				deadLetter(ctx, c.dead, c.opts.Queue, d, fmt.Errorf("bad payment result: %w", err))
 				continue
 			}
-			if err := c.svc.ApplyPaymentResult(ctx, res.OrderID, res.Status); err != nil {
-				log.Printf("apply payment result: %v", err)
+			// Старые сообщения без user_id держим по порядку хотя бы в рамках заказа
+			key := res.UserID
+			if key == "" {
+				key = strconv.FormatInt(res.OrderID, 10)
+			}
+			if !pool.Submit(ctx, key, resultJob{delivery: d, result: res}) {
 				_ = d.Nack(false, true)
-				continue
+				return nil
 			}
-			_ = d.Ack(false)
 		}
 	}
 }
 
+// decodePaymentResult разбирает конверт по type+version; тело без конверта — формат до перехода на exchange
+func decodePaymentResult(body []byte) (events.PaymentResultV1, error) {
+	var res events.PaymentResultV1
+	env, err := events.Decode(body)
+	if errors.Is(err, events.ErrNotEnvelope) {
+		err = json.Unmarshal(body, &res)
+		return res, err
+	}
+	if err != nil {
+		return res, err
+	}
+
+	switch {
+	case (env.Type == events.TypePaymentCompleted || env.Type == events.TypePaymentCancelled || env.Type == events.TypePaymentRefunded) && env.Version == 1:
+		err = json.Unmarshal(env.Data, &res)
+	default:
+		err = fmt.Errorf("unsupported event %s", env.RoutingKey())
+	}
+	return res, err
+}
+
+func (c *PaymentStatusConsumer) handle(ctx context.Context, j resultJob) {
+	// у старых сообщений без конверта message_id пустой, если паблишер его не ставил
+	logger := slog.With(
+		"message_id", j.delivery.MessageId,
+		"order_id", j.result.OrderID,
+		"user_id", j.result.UserID,
+		"correlation_id", j.delivery.CorrelationId,
+		"redelivered", j.delivery.Redelivered,
+	)
+	start := time.Now()
+	attempts, err := c.opts.Retry.Do(ctx, func() error {
+		return c.svc.ApplyPaymentResult(ctx, j.result)
+	})
+	if err != nil {
+		retryExhausted(ctx, logger, c.dead, c.opts.Queue, j.delivery, attempts, err)
+		return
+	}
+	_ = j.delivery.Ack(false)
+	logger.Info("payment result applied",
+		"status", j.result.Status,
+		"reason_code", j.result.ReasonCode,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
@@ -10,6 +10,8 @@ const (
 	StatusNew       = "NEW"
 	StatusFinished  = "FINISHED"
 	StatusCancelled = "CANCELLED"
+	// StatusRefunded — оплаченный заказ, деньги за который поддержка вернула (событие payments.refunded)
+	StatusRefunded = "REFUNDED"
 )
 
 // DBTX прикидывается и *sql.DB, и *sql.Tx — общий контракт
@@ -19,13 +21,30 @@ type DBTX interface {
 	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
+// Order — ключи JSON snake_case, как во всём API; одна форма и в публичном GET /orders, и в админке
 type Order struct {
-	ID          int64
-	UserID      string
-	Amount      int64
-	Description string
-	Status      string
-	CreatedAt   time.Time
+	ID          int64     `json:"id"`
+	UserID      string    `json:"user_id"`
+	Amount      int64     `json:"amount"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	// FailureCode и FailureReason — почему payments отменил оплату (INSUFFICIENT_FUNDS и т.п.), у остальных пусто
+	FailureCode   string `json:"failure_code,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// orderColumns и scanOrder — общий SELECT для всех выборок заказа
+const orderColumns = `id, user_id, amount, description, status, created_at, COALESCE(failure_code, ''), COALESCE(failure_reason, '')`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(s scanner) (Order, error) {
+	var o Order
+	err := s.Scan(&o.ID, &o.UserID, &o.Amount, &o.Description, &o.Status, &o.CreatedAt, &o.FailureCode, &o.FailureReason)
+	return o, err
 }
 
 type Repository struct {
@@ -46,13 +65,15 @@ func (r *Repository) Create(ctx context.Context, tx DBTX, userID string, amount
 	return id, err
 }
 
-func (r *Repository) ListByUser(ctx context.Context, userID string) ([]Order, error) {
+// ListByUser — новые сначала; limit 0 — все заказы (LIMIT NULL в Postgres — без ограничения)
+func (r *Repository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]Order, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, user_id, amount, description, status, created_at
+		SELECT `+orderColumns+`
 		FROM orders
 		WHERE ($1 = '' OR user_id = $1)
-		ORDER BY created_at DESC
-	`, userID)
+		ORDER BY created_at DESC, id DESC
+		LIMIT NULLIF($2, 0) OFFSET $3
+	`, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -60,8 +81,8 @@ func (r *Repository) ListByUser(ctx context.Context, userID string) ([]Order, er
 
 	var res []Order
 	for rows.Next() {
-		var o Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Amount, &o.Description, &o.Status, &o.CreatedAt); err != nil {
+		o, err := scanOrder(rows)
+		if err != nil {
 			return nil, err
 		}
 		res = append(res, o)
@@ -69,13 +90,53 @@ func (r *Repository) ListByUser(ctx context.Context, userID string) ([]Order, er
 	return res, rows.Err()
 }
 
-func (r *Repository) Get(ctx context.Context, id int64) (Order, error) {
-	var o Order
+func (r *Repository) CountByUser(ctx context.Context, userID string) (int, error) {
+	var n int
 	err := r.db.QueryRowContext(ctx, `
-		SELECT id, user_id, amount, description, status, created_at
-		FROM orders WHERE id=$1
-	`, id).Scan(&o.ID, &o.UserID, &o.Amount, &o.Description, &o.Status, &o.CreatedAt)
-	return o, err
+		SELECT count(*) FROM orders WHERE ($1 = '' OR user_id = $1)
+	`, userID).Scan(&n)
+	return n, err
+}
+
+// Stats — счётчики заказов пользователя для сводки: считает БД, без выгрузки всей истории
+type Stats struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	// PendingAmount — сумма заказов, которые ещё ждут оплаты (NEW)
+	PendingAmount int64 `json:"pending_amount"`
+}
+
+func (r *Repository) Stats(ctx context.Context, userID string) (Stats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT status, count(*), COALESCE(sum(amount), 0)
+		FROM orders
+		WHERE ($1 = '' OR user_id = $1)
+		GROUP BY status
+	`, userID)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer rows.Close()
+
+	res := Stats{ByStatus: map[string]int{}}
+	for rows.Next() {
+		var status string
+		var n int
+		var amount int64
+		if err := rows.Scan(&status, &n, &amount); err != nil {
+			return Stats{}, err
+		}
+		res.Total += n
+		res.ByStatus[status] = n
+		if status == StatusNew {
+			res.PendingAmount = amount
+		}
+	}
+	return res, rows.Err()
+}
+
+func (r *Repository) Get(ctx context.Context, id int64) (Order, error) {
+	return scanOrder(r.db.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id=$1`, id))
 }
 
 func (r *Repository) UpdateStatus(ctx context.Context, tx DBTX, id int64, fromStatus, toStatus string) error {
@@ -87,3 +148,99 @@ func (r *Repository) UpdateStatus(ctx context.Context, tx DBTX, id int64, fromSt
 	return err
 }
 
+// Cancel — отмена по результату оплаты; причина от payments остаётся на заказе, её видит покупатель
+func (r *Repository) Cancel(ctx context.Context, tx DBTX, id int64, fromStatus, code, reason string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status = $1, failure_code = NULLIF($2, ''), failure_reason = NULLIF($3, '')
+		WHERE id = $4 AND status = $5
+	`, StatusCancelled, code, reason, id, fromStatus)
+	return err
+}
+
+// SearchFilter — поиск заказов в админке; пустые поля не участвуют
+type SearchFilter struct {
+	UserID string
+	Status string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+func (r *Repository) Search(ctx context.Context, f SearchFilter) ([]Order, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE ($1 = '' OR user_id = $1)
+		  AND ($2 = '' OR status = $2)
+		  AND ($3::timestamp IS NULL OR created_at >= $3)
+		  AND ($4::timestamp IS NULL OR created_at < $4)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $5
+	`, f.UserID, f.Status, nullTime(f.From), nullTime(f.To), f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, o)
+	}
+	return res, rows.Err()
+}
+
+func (r *Repository) GetForUpdate(ctx context.Context, tx DBTX, id int64) (Order, error) {
+	return scanOrder(tx.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id=$1 FOR UPDATE`, id))
+}
+
+// StatusChange — ручная смена статуса из админки
+type StatusChange struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	OperatorID string    `json:"operator_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (r *Repository) InsertStatusChange(ctx context.Context, tx DBTX, orderID int64, c StatusChange) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO order_status_history(order_id, from_status, to_status, reason, operator_id)
+		VALUES ($1,$2,$3,$4,$5)
+	`, orderID, c.FromStatus, c.ToStatus, c.Reason, c.OperatorID)
+	return err
+}
+
+func (r *Repository) StatusHistory(ctx context.Context, orderID int64) ([]StatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT from_status, to_status, reason, operator_id, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []StatusChange{}
+	for rows.Next() {
+		var c StatusChange
+		if err := rows.Scan(&c.FromStatus, &c.ToStatus, &c.Reason, &c.OperatorID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
@@ -3,10 +3,18 @@ package order
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 
-	"github.com/google/uuid"
+	"github.com/example/webshop/orders/internal/events"
+	"github.com/example/webshop/orders/internal/logging"
 	"github.com/example/webshop/orders/internal/outbox"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound        = errors.New("order not found")
+	ErrInvalidStatus   = errors.New("unknown order status")
+	ErrStatusUnchanged = errors.New("order already has this status")
 )
 
 type Service struct {
@@ -15,13 +23,6 @@ type Service struct {
 	outbox *outbox.Repository
 }
 
-type PaymentTask struct {
-	MessageID string `json:"message_id"`
-	OrderID   int64  `json:"order_id"`
-	UserID    string `json:"user_id"`
-	Amount    int64  `json:"amount"`
-}
-
 func NewService(db *sql.DB, repo *Repository, outboxRepo *outbox.Repository) *Service {
 	return &Service{db: db, repo: repo, outbox: outboxRepo}
 }
@@ -38,13 +39,21 @@ func (s *Service) CreateOrder(ctx context.Context, userID string, amount int64,
 		return Order{}, nil, uuid.Nil, err
 	}
 
+	// Корреляция на весь флоу заказа: orders.created → payments.* несут один correlation_id.
+	// Берём request ID из gateway, чтобы HTTP-запрос и сообщения искались по одному ключу
+	correlationID := logging.RequestID(ctx)
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
 	messageID := uuid.New()
-	payload, _ := json.Marshal(PaymentTask{
-		MessageID: messageID.String(),
-		OrderID:   orderID,
-		UserID:    userID,
-		Amount:    amount,
+	payload, err := events.New(messageID, events.TypeOrderCreated, 1, correlationID, events.OrderCreatedV1{
+		OrderID: orderID,
+		UserID:  userID,
+		Amount:  amount,
 	})
+	if err != nil {
+		return Order{}, nil, uuid.Nil, err
+	}
 
 	if err := s.outbox.Insert(ctx, tx, messageID, orderID, userID, amount, payload); err != nil {
 		return Order{}, nil, uuid.Nil, err
@@ -64,29 +73,94 @@ func (s *Service) CreateOrder(ctx context.Context, userID string, amount int64,
 }
 
 func (s *Service) ListOrders(ctx context.Context, userID string) ([]Order, error) {
-	return s.repo.ListByUser(ctx, userID)
+	return s.repo.ListByUser(ctx, userID, 0, 0)
+}
+
+func (s *Service) Stats(ctx context.Context, userID string) (Stats, error) {
+	return s.repo.Stats(ctx, userID)
+}
+
+// ListOrdersPage — страница истории заказов и сколько их всего, чтобы клиент посчитал страницы
+func (s *Service) ListOrdersPage(ctx context.Context, userID string, limit, offset int) ([]Order, int, error) {
+	total, err := s.repo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	items, err := s.repo.ListByUser(ctx, userID, limit, offset)
+	return items, total, err
 }
 
 func (s *Service) GetOrder(ctx context.Context, id int64) (Order, error) {
-	return s.repo.Get(ctx, id)
+	o, err := s.repo.Get(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrNotFound
+	}
+	return o, err
+}
+
+func (s *Service) Search(ctx context.Context, f SearchFilter) ([]Order, error) {
+	return s.repo.Search(ctx, f)
+}
+
+func (s *Service) StatusHistory(ctx context.Context, orderID int64) ([]StatusChange, error) {
+	return s.repo.StatusHistory(ctx, orderID)
 }
 
-func (s *Service) ApplyPaymentResult(ctx context.Context, orderID int64, status string) error {
+// ForceStatus — ручная смена статуса поддержкой. Событий не шлёт: это исправление данных,
+// деньги за оплаченный заказ возвращает refund в payments.
+func (s *Service) ForceStatus(ctx context.Context, id int64, status, reason, operatorID string) (Order, error) {
+	if status != StatusNew && status != StatusFinished && status != StatusCancelled {
+		return Order{}, ErrInvalidStatus
+	}
 	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return err
+		return Order{}, err
 	}
 	defer tx.Rollback()
 
-	target := StatusCancelled
-	if status == StatusFinished {
-		target = StatusFinished
+	o, err := s.repo.GetForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrNotFound
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	if o.Status == status {
+		return Order{}, ErrStatusUnchanged
+	}
+	if err := s.repo.UpdateStatus(ctx, tx, id, o.Status, status); err != nil {
+		return Order{}, err
+	}
+	change := StatusChange{FromStatus: o.Status, ToStatus: status, Reason: reason, OperatorID: operatorID}
+	if err := s.repo.InsertStatusChange(ctx, tx, id, change); err != nil {
+		return Order{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Order{}, err
+	}
+	o.Status = status
+	return o, nil
+}
+
+func (s *Service) ApplyPaymentResult(ctx context.Context, res events.PaymentResultV1) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	if err := s.repo.UpdateStatus(ctx, tx, orderID, StatusNew, target); err != nil {
+	switch res.Status {
+	case StatusFinished:
+		err = s.repo.UpdateStatus(ctx, tx, res.OrderID, StatusNew, StatusFinished)
+	case StatusRefunded:
+		// Вернуть можно только оплаченное
+		err = s.repo.UpdateStatus(ctx, tx, res.OrderID, StatusFinished, StatusRefunded)
+	default:
+		err = s.repo.Cancel(ctx, tx, res.OrderID, StatusNew, res.ReasonCode, res.Reason)
+	}
+	if err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
-
@@ -2,117 +2,83 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/go-chi/chi/v5"
-	_ "github.com/jackc/pgx/v5/stdlib"
-	amqp "github.com/rabbitmq/amqp091-go"
-
-	"github.com/example/webshop/orders/internal/config"
-	"github.com/example/webshop/orders/internal/db"
-	httpapi "github.com/example/webshop/orders/internal/http"
-	"github.com/example/webshop/orders/internal/mq"
-	"github.com/example/webshop/orders/internal/order"
-	"github.com/example/webshop/orders/internal/outbox"
+	"github.com/example/webshop/orders/app"
+	"github.com/example/webshop/orders/internal/logging"
 )
 
-func main() {
-	ctx, stop := context.WithCancel(context.Background())
-	defer stop()
+const usage = `usage: orders [config print]
 
-	cfg := config.Load()
+Без аргументов запускает сервис. Конфиг: CONFIG_FILE (YAML) + env, секреты — через *_FILE.
+  config print  показать итоговый конфиг без секретов и ошибки валидации
+`
 
-	dbConn, err := sql.Open("pgx", cfg.DBURL)
-	if err != nil {
-		log.Fatalf("open db: %v", err)
+func main() {
+	if len(os.Args) > 1 {
+		os.Exit(command(os.Args[1:]))
 	}
-	defer dbConn.Close()
 
-	if err := waitForDB(ctx, dbConn, 30*time.Second); err != nil {
-		log.Fatalf("db not ready: %v", err)
+	cfg, err := app.LoadConfig()
+	logging.Setup("orders-service", cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		slog.Error("invalid config", "err", err)
+		os.Exit(1)
 	}
 
-	if err := db.Migrate(ctx, dbConn); err != nil {
-		log.Fatalf("db migrate: %v", err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	rmq, err := waitForRabbit(ctx, cfg.RabbitURL, 40*time.Second)
+	a, err := app.New(ctx, cfg)
 	if err != nil {
-		log.Fatalf("rabbit not ready: %v", err)
+		slog.Error("init failed", "err", err)
+		os.Exit(1)
 	}
-	defer rmq.Close()
+	defer a.Close()
 
-	ch, err := rmq.Channel()
-	if err != nil {
-		log.Fatalf("channel: %v", err)
-	}
-	defer ch.Close()
+	go a.Run(ctx)
 
-	if err := declareQueues(ch); err != nil {
-		log.Fatalf("declare queues: %v", err)
+	srv := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      a.Handler(),
+		ReadTimeout:  cfg.HTTPReadTimeout,
+		WriteTimeout: cfg.HTTPWriteTimeout,
+		IdleTimeout:  cfg.HTTPIdleTimeout,
 	}
-
-	orderRepo := order.NewRepository(dbConn)
-	outboxRepo := outbox.NewRepository(dbConn)
-	svc := order.NewService(dbConn, orderRepo, outboxRepo)
-
-	outboxPub := mq.NewOutboxPublisher(dbConn, outboxRepo, ch)
-	statusConsumer := mq.NewPaymentStatusConsumer(svc, ch)
-
-	go outboxPub.Run(ctx)
 	go func() {
-		if err := statusConsumer.Run(ctx); err != nil {
-			log.Fatalf("payment status consumer: %v", err)
-		}
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
 	}()
 
-	handler := httpapi.NewHandler(svc)
-	r := chi.NewRouter()
-	r.Mount("/", handler.Router())
-
-	log.Printf("orders service listening on :%s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
-		log.Fatalf("http server: %v", err)
-	}
-}
-
-func declareQueues(ch *amqp.Channel) error {
-	if _, err := ch.QueueDeclare("order.payments", true, false, false, false, nil); err != nil {
-		return err
-	}
-	if _, err := ch.QueueDeclare("payment.status", true, false, false, false, nil); err != nil {
-		return err
-	}
-	return nil
-}
-
-func waitForDB(ctx context.Context, db *sql.DB, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for {
-		if err := db.PingContext(ctx); err == nil {
-			return nil
-		}
-		if time.Now().After(deadline) {
-			return context.DeadlineExceeded
-		}
-		time.Sleep(time.Second)
+	slog.Info("listening", "port", cfg.Port)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("http server failed", "err", err)
+		os.Exit(1)
 	}
+	slog.Info("stopped")
 }
 
-func waitForRabbit(ctx context.Context, url string, timeout time.Duration) (*amqp.Connection, error) {
-	deadline := time.Now().Add(timeout)
-	for {
-		conn, err := amqp.Dial(url)
-		if err == nil {
-			return conn, nil
+func command(args []string) int {
+	if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+		cfg, err := app.LoadConfig()
+		if perr := cfg.Print(os.Stdout); perr != nil {
+			fmt.Fprintln(os.Stderr, perr)
+			return 1
 		}
-		if time.Now().After(deadline) {
-			return nil, err
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "config is invalid:\n%v\n", err)
+			return 1
 		}
-		time.Sleep(time.Second)
+		return 0
 	}
+	fmt.Fprint(os.Stderr, usage)
+	return 2
 }
-
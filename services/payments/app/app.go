@@ -0,0 +1,191 @@
+// Package app собирает payments-service целиком: БД, RabbitMQ-сессию, фоновые джобы и HTTP-роутер.
+// Вынесен из main, чтобы e2e-тесты могли поднять сервис в своём процессе.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/example/webshop/payments/internal/account"
+	"github.com/example/webshop/payments/internal/config"
+	"github.com/example/webshop/payments/internal/db"
+	"github.com/example/webshop/payments/internal/deadletter"
+	httpapi "github.com/example/webshop/payments/internal/http"
+	"github.com/example/webshop/payments/internal/inbox"
+	"github.com/example/webshop/payments/internal/janitor"
+	"github.com/example/webshop/payments/internal/logging"
+	"github.com/example/webshop/payments/internal/mq"
+	"github.com/example/webshop/payments/internal/outbox"
+	"github.com/example/webshop/payments/internal/payment"
+	"github.com/example/webshop/payments/internal/risk"
+)
+
+// Config — алиас внутреннего конфига, чтобы его можно было собрать снаружи модуля
+type Config = config.Config
+
+// LoadConfig читает CONFIG_FILE и env и валидирует результат
+func LoadConfig() (Config, error) {
+	return config.Load()
+}
+
+// DefaultConfig — дефолты без файла и env; адреса БД и брокера надо задать самому
+func DefaultConfig() Config {
+	return config.Default()
+}
+
+type App struct {
+	cfg      Config
+	db       *sql.DB
+	payments *payment.Service
+	outbox   *outbox.Repository
+	dead     *deadletter.Repository
+	topo     mq.Topology
+	session  *mq.Session
+	cleaner  *janitor.Janitor
+	router   *chi.Mux
+}
+
+func New(ctx context.Context, cfg Config) (*App, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	dbConn, err := sql.Open("pgx", cfg.DBURL)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	dbConn.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	dbConn.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	dbConn.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	dbConn.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	if err := waitForDB(ctx, dbConn, cfg.DBConnectTimeout); err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("db not ready: %w", err)
+	}
+	if err := db.Migrate(ctx, dbConn); err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("db migrate: %w", err)
+	}
+
+	accountRepo := account.NewRepository(dbConn)
+	paymentRepo := payment.NewRepository(dbConn)
+	inboxRepo := inbox.NewRepository(dbConn)
+	outboxRepo := outbox.NewRepository(dbConn)
+	riskRepo := risk.NewRepository(dbConn)
+	deadRepo := deadletter.NewRepository(dbConn)
+	riskEngine := risk.NewEngine(riskRepo)
+
+	paymentSvc := payment.NewService(dbConn, accountRepo, paymentRepo, inboxRepo, outboxRepo, riskEngine, riskRepo, payment.TxTimeouts{
+		Statement: cfg.PaymentStatementTimeout,
+		Lock:      cfg.PaymentLockTimeout,
+	})
+	accountSvc := account.NewService(dbConn, accountRepo)
+	topo := mq.Topology{OrderPayments: cfg.QueueOrderPayments, PaymentStatus: cfg.QueuePaymentStatus}
+	session := mq.NewSession(cfg.RabbitURL, cfg.RabbitReconnectDelay, topo.Declare)
+
+	cleaner := janitor.New(outboxRepo, inboxRepo, janitor.Options{
+		OutboxRetention: cfg.OutboxRetention,
+		InboxRetention:  cfg.InboxRetention,
+		Interval:        cfg.JanitorInterval,
+		BatchSize:       cfg.JanitorBatchSize,
+		Archive:         cfg.OutboxArchive,
+	})
+
+	handler := httpapi.NewHandler(accountSvc, paymentSvc, riskRepo)
+	r := chi.NewRouter()
+	r.Use(logging.Middleware)
+	r.NotFound(httpapi.NotFound)
+	r.MethodNotAllowed(httpapi.MethodNotAllowed)
+	r.Handle("/debug/vars", expvar.Handler())
+	r.Get("/debug/db", httpapi.DBStats(dbConn))
+	r.Get("/healthz", httpapi.Healthz(dbConn))
+	r.Mount("/admin/replay", httpapi.NewReplayHandler(outboxRepo, inboxRepo, deadRepo, session, topo).Router())
+	r.With(httpapi.RequestTimeout(cfg.HTTPRequestTimeout)).Mount("/admin/payments", httpapi.NewAdminHandler(paymentSvc, paymentRepo, outboxRepo, inboxRepo).Router())
+	r.With(httpapi.RequestTimeout(cfg.HTTPRequestTimeout)).Mount("/", handler.Router())
+
+	return &App{
+		cfg:      cfg,
+		db:       dbConn,
+		payments: paymentSvc,
+		outbox:   outboxRepo,
+		dead:     deadRepo,
+		topo:     topo,
+		session:  session,
+		cleaner:  cleaner,
+		router:   r,
+	}, nil
+}
+
+func (a *App) Handler() http.Handler {
+	return a.router
+}
+
+// Run крутит фоновые джобы до отмены ctx; RabbitMQ переподключается сам
+func (a *App) Run(ctx context.Context) {
+	go a.cleaner.Run(ctx)
+	a.session.Run(ctx, a.startMessaging)
+}
+
+func (a *App) Close() error {
+	return a.db.Close()
+}
+
+// startMessaging запускает консьюмер и паблишер на канале сессии; умер консьюмер — гасим и паблишер,
+// чтобы сессия пересоздалась целиком
+func (a *App) startMessaging(ctx context.Context, ch *amqp.Channel) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	orderConsumer := mq.NewOrderConsumer(a.payments, a.dead, ch, a.consumerOptions(a.topo.OrderPayments))
+	outboxPublisher := mq.NewOutboxPublisher(a.db, a.outbox, ch, a.cfg.DBURL, a.cfg.OutboxInterval, a.cfg.OutboxBatchSize, a.topo.PaymentStatus)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		if err := orderConsumer.Run(ctx); err != nil {
+			slog.Error("order consumer stopped", "err", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		outboxPublisher.Run(ctx)
+	}()
+	wg.Wait()
+}
+
+func (a *App) consumerOptions(queue string) mq.ConsumerOptions {
+	return mq.ConsumerOptions{
+		Queue:    queue,
+		Prefetch: a.cfg.ConsumerPrefetch,
+		Workers:  a.cfg.ConsumerWorkers,
+		Retry: mq.RetryPolicy{
+			MaxAttempts:    a.cfg.RetryMaxAttempts,
+			InitialBackoff: a.cfg.RetryInitialBackoff,
+			MaxBackoff:     a.cfg.RetryMaxBackoff,
+		},
+	}
+}
+
+func waitForDB(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return context.DeadlineExceeded
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -3,6 +3,13 @@ package account
 import (
 	"context"
 	"database/sql"
+	"time"
+)
+
+const (
+	StatusActive = "ACTIVE"
+	StatusFrozen = "FROZEN"
+	StatusClosed = "CLOSED"
 )
 
 // DBTX — общий мини-интерфейс для *sql.DB и *sql.Tx
@@ -11,6 +18,15 @@ type DBTX interface {
 	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
+type Account struct {
+	UserID          string     `json:"user_id"`
+	Balance         int64      `json:"balance"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
 type Repository struct {
 	db *sql.DB
 }
@@ -35,9 +51,10 @@ func (r *Repository) CreateIfAbsent(ctx context.Context, userID string) (bool, e
 	return true, nil
 }
 
-func (r *Repository) Deposit(ctx context.Context, userID string, amount int64) (int64, error) {
+// Credit прибавляет amount (у корректировок он бывает отрицательным); статус проверяет вызывающий под FOR UPDATE
+func (r *Repository) Credit(ctx context.Context, tx DBTX, userID string, amount int64) (int64, error) {
 	var balance int64
-	err := r.db.QueryRowContext(ctx, `
+	err := tx.QueryRowContext(ctx, `
 		UPDATE accounts SET balance = balance + $1
 		WHERE user_id = $2
 		RETURNING balance
@@ -45,26 +62,130 @@ func (r *Repository) Deposit(ctx context.Context, userID string, amount int64) (
 	return balance, err
 }
 
-func (r *Repository) Balance(ctx context.Context, userID string) (int64, error) {
-	var balance int64
-	err := r.db.QueryRowContext(ctx, `
-		SELECT balance FROM accounts WHERE user_id=$1
-	`, userID).Scan(&balance)
-	return balance, err
+func (r *Repository) Get(ctx context.Context, userID string) (Account, error) {
+	return r.get(ctx, r.db, userID, false)
 }
 
-func (r *Repository) BalanceForUpdate(ctx context.Context, tx DBTX, userID string) (int64, error) {
-	var balance int64
-	err := tx.QueryRowContext(ctx, `
-		SELECT balance FROM accounts WHERE user_id=$1 FOR UPDATE
-	`, userID).Scan(&balance)
-	return balance, err
+func (r *Repository) GetForUpdate(ctx context.Context, tx DBTX, userID string) (Account, error) {
+	return r.get(ctx, tx, userID, true)
+}
+
+func (r *Repository) get(ctx context.Context, q DBTX, userID string, forUpdate bool) (Account, error) {
+	query := `
+		SELECT user_id, balance, status, COALESCE(status_reason, ''), status_changed_at, created_at
+		FROM accounts WHERE user_id=$1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var a Account
+	err := q.QueryRowContext(ctx, query, userID).
+		Scan(&a.UserID, &a.Balance, &a.Status, &a.StatusReason, &a.StatusChangedAt, &a.CreatedAt)
+	return a, err
 }
 
+// Deduct не трогает замороженные/закрытые счета даже если кто-то забыл проверить статус
 func (r *Repository) Deduct(ctx context.Context, tx DBTX, userID string, amount int64) error {
 	_, err := tx.ExecContext(ctx, `
-		UPDATE accounts SET balance = balance - $1 WHERE user_id=$2
-	`, amount, userID)
+		UPDATE accounts SET balance = balance - $1 WHERE user_id=$2 AND status=$3
+	`, amount, userID, StatusActive)
+	return err
+}
+
+// SetStatus меняет статус и пишет историю для комплаенса (с оператором), всё в одной транзакции
+func (r *Repository) SetStatus(ctx context.Context, tx DBTX, userID, fromStatus, toStatus, reason, operatorID string) error {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE accounts
+		SET status = $1, status_reason = $2, status_changed_at = now()
+		WHERE user_id = $3
+	`, toStatus, reason, userID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO account_status_history(user_id, from_status, to_status, reason, operator_id)
+		VALUES ($1,$2,$3,$4,$5)
+	`, userID, fromStatus, toStatus, reason, operatorID)
 	return err
 }
 
+// ListFilter — выборка счетов для админки; nil-границы баланса не участвуют
+type ListFilter struct {
+	Status     string
+	MinBalance *int64
+	MaxBalance *int64
+	// Asc — по возрастанию баланса, по умолчанию сначала самые большие
+	Asc   bool
+	Limit int
+}
+
+func (r *Repository) List(ctx context.Context, f ListFilter) ([]Account, error) {
+	order := "DESC"
+	if f.Asc {
+		order = "ASC"
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT user_id, balance, status, COALESCE(status_reason, ''), status_changed_at, created_at
+		FROM accounts
+		WHERE ($1 = '' OR status = $1)
+		  AND ($2::bigint IS NULL OR balance >= $2)
+		  AND ($3::bigint IS NULL OR balance <= $3)
+		ORDER BY balance `+order+`, user_id
+		LIMIT $4
+	`, f.Status, f.MinBalance, f.MaxBalance, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []Account{}
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.UserID, &a.Balance, &a.Status, &a.StatusReason, &a.StatusChangedAt, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
+// Adjustment — ручная корректировка баланса поддержкой; amount со знаком
+type Adjustment struct {
+	ID           int64     `json:"id"`
+	UserID       string    `json:"user_id"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balance_after"`
+	Reason       string    `json:"reason"`
+	OperatorID   string    `json:"operator_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (r *Repository) InsertAdjustment(ctx context.Context, tx DBTX, a Adjustment) (Adjustment, error) {
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO balance_adjustments(user_id, amount, balance_after, reason, operator_id)
+		VALUES ($1,$2,$3,$4,$5)
+		RETURNING id, created_at
+	`, a.UserID, a.Amount, a.BalanceAfter, a.Reason, a.OperatorID).Scan(&a.ID, &a.CreatedAt)
+	return a, err
+}
+
+func (r *Repository) Adjustments(ctx context.Context, userID string) ([]Adjustment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, amount, balance_after, reason, operator_id, created_at
+		FROM balance_adjustments
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []Adjustment{}
+	for rows.Next() {
+		var a Adjustment
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Amount, &a.BalanceAfter, &a.Reason, &a.OperatorID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
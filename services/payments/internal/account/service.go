@@ -0,0 +1,188 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrNotFound          = errors.New("account not found")
+	ErrFrozen            = errors.New("account frozen")
+	ErrClosed            = errors.New("account closed")
+	ErrNonZeroBalance    = errors.New("account balance is not zero")
+	ErrInvalidTransition = errors.New("invalid account status transition")
+	ErrNegativeBalance   = errors.New("adjustment would make balance negative")
+)
+
+// Action — админское действие над жизненным циклом счёта
+type Action string
+
+const (
+	ActionFreeze   Action = "freeze"
+	ActionUnfreeze Action = "unfreeze"
+	ActionClose    Action = "close"
+	ActionReopen   Action = "reopen"
+)
+
+// transitions: из каких статусов куда можно перейти по действию
+var transitions = map[Action]struct {
+	from []string
+	to   string
+}{
+	ActionFreeze:   {from: []string{StatusActive}, to: StatusFrozen},
+	ActionUnfreeze: {from: []string{StatusFrozen}, to: StatusActive},
+	ActionClose:    {from: []string{StatusActive, StatusFrozen}, to: StatusClosed},
+	ActionReopen:   {from: []string{StatusClosed}, to: StatusActive},
+}
+
+type Service struct {
+	db   *sql.DB
+	repo *Repository
+}
+
+func NewService(db *sql.DB, repo *Repository) *Service {
+	return &Service{db: db, repo: repo}
+}
+
+func (s *Service) Create(ctx context.Context, userID string) (bool, error) {
+	return s.repo.CreateIfAbsent(ctx, userID)
+}
+
+func (s *Service) Get(ctx context.Context, userID string) (Account, error) {
+	a, err := s.repo.Get(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Account{}, ErrNotFound
+	}
+	return a, err
+}
+
+// Deposit пополняет только активный счёт
+func (s *Service) Deposit(ctx context.Context, userID string, amount int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	a, err := s.repo.GetForUpdate(ctx, tx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := CheckActive(a); err != nil {
+		return 0, err
+	}
+
+	balance, err := s.repo.Credit(ctx, tx, userID, amount)
+	if err != nil {
+		return 0, err
+	}
+	return balance, tx.Commit()
+}
+
+// ChangeStatus выполняет freeze/unfreeze/close/reopen под локом строки счёта; operatorID уходит в историю
+func (s *Service) ChangeStatus(ctx context.Context, userID string, action Action, reason, operatorID string) (Account, error) {
+	t, ok := transitions[action]
+	if !ok {
+		return Account{}, ErrInvalidTransition
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return Account{}, err
+	}
+	defer tx.Rollback()
+
+	a, err := s.repo.GetForUpdate(ctx, tx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Account{}, ErrNotFound
+	}
+	if err != nil {
+		return Account{}, err
+	}
+	if !slices.Contains(t.from, a.Status) {
+		return Account{}, ErrInvalidTransition
+	}
+	// Закрываем только пустой счёт, деньги сначала надо вывести
+	if t.to == StatusClosed && a.Balance != 0 {
+		return Account{}, ErrNonZeroBalance
+	}
+
+	if err := s.repo.SetStatus(ctx, tx, userID, a.Status, t.to, reason, operatorID); err != nil {
+		return Account{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Account{}, err
+	}
+	return s.Get(ctx, userID)
+}
+
+func (s *Service) List(ctx context.Context, f ListFilter) ([]Account, error) {
+	return s.repo.List(ctx, f)
+}
+
+func (s *Service) Adjustments(ctx context.Context, userID string) ([]Adjustment, error) {
+	if _, err := s.Get(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.Adjustments(ctx, userID)
+}
+
+// Adjust — ручная корректировка баланса (возврат, компенсация, исправление ошибки).
+// Замороженный счёт корректировать можно, закрытый — нет; в минус баланс не уводим.
+func (s *Service) Adjust(ctx context.Context, userID string, amount int64, reason, operatorID string) (Adjustment, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return Adjustment{}, err
+	}
+	defer tx.Rollback()
+
+	a, err := s.repo.GetForUpdate(ctx, tx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Adjustment{}, ErrNotFound
+	}
+	if err != nil {
+		return Adjustment{}, err
+	}
+	if a.Status == StatusClosed {
+		return Adjustment{}, ErrClosed
+	}
+	if a.Balance+amount < 0 {
+		return Adjustment{}, ErrNegativeBalance
+	}
+
+	balance, err := s.repo.Credit(ctx, tx, userID, amount)
+	if err != nil {
+		return Adjustment{}, err
+	}
+	adj, err := s.repo.InsertAdjustment(ctx, tx, Adjustment{
+		UserID:       userID,
+		Amount:       amount,
+		BalanceAfter: balance,
+		Reason:       reason,
+		OperatorID:   operatorID,
+	})
+	if err != nil {
+		return Adjustment{}, err
+	}
+	return adj, tx.Commit()
+}
+
+// CheckActive возвращает ошибку статуса, если со счётом нельзя проводить операции
+func CheckActive(a Account) error {
+	switch a.Status {
+	case StatusActive:
+		return nil
+	case StatusFrozen:
+		return ErrFrozen
+	case StatusClosed:
+		return ErrClosed
+	default:
+		return fmt.Errorf("unknown account status %q", a.Status)
+	}
+}
@@ -13,6 +13,34 @@ CREATE TABLE IF NOT EXISTS accounts (
 	balance BIGINT NOT NULL DEFAULT 0,
 	created_at TIMESTAMP NOT NULL DEFAULT now()
 );
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'ACTIVE';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS account_status_history (
+	id BIGSERIAL PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES accounts(user_id),
+	from_status TEXT NOT NULL,
+	to_status TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS account_status_history_user_idx ON account_status_history(user_id, created_at);
+-- кто из операторов сменил статус; у строк, записанных до колонки, NULL
+ALTER TABLE account_status_history ADD COLUMN IF NOT EXISTS operator_id TEXT;
+
+CREATE INDEX IF NOT EXISTS accounts_balance_idx ON accounts(balance);
+
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+	id BIGSERIAL PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES accounts(user_id),
+	amount BIGINT NOT NULL,
+	balance_after BIGINT NOT NULL,
+	reason TEXT NOT NULL,
+	operator_id TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS balance_adjustments_user_idx ON balance_adjustments(user_id, created_at);
 
 CREATE TABLE IF NOT EXISTS payments (
 	order_id INT PRIMARY KEY,
@@ -21,11 +49,37 @@ CREATE TABLE IF NOT EXISTS payments (
 	status TEXT NOT NULL,
 	created_at TIMESTAMP NOT NULL DEFAULT now()
 );
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS reason_code TEXT;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS reason TEXT;
+CREATE INDEX IF NOT EXISTS payments_user_created_idx ON payments(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS account_limits (
+	user_id TEXT PRIMARY KEY REFERENCES accounts(user_id),
+	max_single_payment BIGINT NOT NULL DEFAULT 0,
+	daily_limit BIGINT NOT NULL DEFAULT 0,
+	monthly_limit BIGINT NOT NULL DEFAULT 0,
+	max_orders_per_hour BIGINT NOT NULL DEFAULT 0,
+	review_amount BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS payment_reviews (
+	order_id INT PRIMARY KEY REFERENCES payments(order_id),
+	user_id TEXT NOT NULL,
+	amount BIGINT NOT NULL,
+	codes TEXT NOT NULL,
+	status TEXT NOT NULL,
+	note TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	resolved_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS payment_reviews_status_idx ON payment_reviews(status, created_at);
 
 CREATE TABLE IF NOT EXISTS inbox (
 	message_id UUID PRIMARY KEY,
 	received_at TIMESTAMP NOT NULL DEFAULT now()
 );
+CREATE INDEX IF NOT EXISTS inbox_received_idx ON inbox(received_at);
 
 CREATE TABLE IF NOT EXISTS outbox (
 	id UUID PRIMARY KEY,
@@ -34,8 +88,29 @@ CREATE TABLE IF NOT EXISTS outbox (
 	created_at TIMESTAMP NOT NULL DEFAULT now()
 );
 CREATE INDEX IF NOT EXISTS outbox_published_idx ON outbox(published_at, created_at);
+
+CREATE TABLE IF NOT EXISTS outbox_archive (
+	id UUID PRIMARY KEY,
+	payload JSONB NOT NULL,
+	published_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL,
+	archived_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id BIGSERIAL PRIMARY KEY,
+	queue TEXT NOT NULL,
+	message_id TEXT,
+	correlation_id TEXT,
+	routing_key TEXT,
+	body BYTEA NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	redriven_at TIMESTAMP,
+	redriven_by TEXT
+);
+CREATE INDEX IF NOT EXISTS dead_letters_pending_idx ON dead_letters(created_at) WHERE redriven_at IS NULL;
 `
 	_, err := db.ExecContext(ctx, schema)
 	return err
 }
-
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// SetLocalTimeouts ставит statement_timeout и lock_timeout на текущую транзакцию (как SET LOCAL,
+// но с параметрами). После коммита или отката соединение возвращается в пул с дефолтами. 0 — не трогать.
+func SetLocalTimeouts(ctx context.Context, tx *sql.Tx, statement, lock time.Duration) error {
+	settings := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"statement_timeout", statement},
+		{"lock_timeout", lock},
+	}
+	for _, s := range settings {
+		if s.value <= 0 {
+			continue
+		}
+		ms := strconv.FormatInt(s.value.Milliseconds(), 10)
+		if _, err := tx.ExecContext(ctx, `SELECT set_config($1, $2, true)`, s.name, ms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
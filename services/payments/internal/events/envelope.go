@@ -0,0 +1,85 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Exchange — общий durable topic exchange; ключи вида <type>.v<version>, например orders.created.v1
+const Exchange = "webshop.events"
+
+// Producer подписывает все события этого сервиса
+const Producer = "payments-service"
+
+const (
+	TypeOrderCreated     = "orders.created"
+	TypePaymentCompleted = "payments.completed"
+	TypePaymentCancelled = "payments.cancelled"
+	// TypePaymentRefunded — поддержка вернула деньги за оплаченный заказ; данные те же, что у completed/cancelled
+	TypePaymentRefunded = "payments.refunded"
+)
+
+// ErrNotEnvelope — тело без конверта (сообщения, опубликованные до перехода на конверт)
+var ErrNotEnvelope = errors.New("message is not an event envelope")
+
+// Envelope — общий конверт всех сообщений; полезная нагрузка лежит в Data и меняется только с версией
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	Timestamp     time.Time       `json:"timestamp"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Producer      string          `json:"producer"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// OrderCreatedV1 — orders.created v1: заказ создан, его надо оплатить
+type OrderCreatedV1 struct {
+	OrderID int64  `json:"order_id"`
+	UserID  string `json:"user_id"`
+	Amount  int64  `json:"amount"`
+}
+
+// PaymentResultV1 — payments.completed / payments.cancelled / payments.refunded v1
+type PaymentResultV1 struct {
+	OrderID    int64  `json:"order_id"`
+	UserID     string `json:"user_id"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	ReasonCode string `json:"reason_code,omitempty"`
+}
+
+func New(id uuid.UUID, typ string, version int, correlationID string, data any) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		ID:            id.String(),
+		Type:          typ,
+		Version:       version,
+		Timestamp:     time.Now().UTC(),
+		CorrelationID: correlationID,
+		Producer:      Producer,
+		Data:          raw,
+	})
+}
+
+func Decode(body []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return Envelope{}, err
+	}
+	if env.Type == "" || env.Version == 0 {
+		return Envelope{}, ErrNotEnvelope
+	}
+	return env, nil
+}
+
+func (e Envelope) RoutingKey() string {
+	return fmt.Sprintf("%s.v%d", e.Type, e.Version)
+}
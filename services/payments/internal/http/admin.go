@@ -0,0 +1,220 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/webshop/payments/internal/account"
+	"github.com/example/webshop/payments/internal/inbox"
+	"github.com/example/webshop/payments/internal/logging"
+	"github.com/example/webshop/payments/internal/outbox"
+	"github.com/example/webshop/payments/internal/payment"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// OperatorHeader — кто из поддержки выполняет действие. Ставит gateway после проверки токена,
+// сам сервис токены не проверяет.
+const OperatorHeader = "X-Operator-ID"
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// listAccounts: ?status=&min_balance=&max_balance=&sort=balance_desc|balance_asc&limit=
+func (h *Handler) listAccounts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := account.ListFilter{Status: strings.ToUpper(q.Get("status")), Limit: defaultListLimit}
+	for name, dst := range map[string]**int64{"min_balance": &f.MinBalance, "max_balance": &f.MaxBalance} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, CodeValidation, name+" must be an integer")
+				return
+			}
+			*dst = &n
+		}
+	}
+	switch q.Get("sort") {
+	case "", "balance_desc":
+	case "balance_asc":
+		f.Asc = true
+	default:
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "sort must be balance_desc or balance_asc")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidation, "limit must be 1.."+strconv.Itoa(maxListLimit))
+			return
+		}
+		f.Limit = n
+	}
+
+	items, err := h.accounts.List(r.Context(), f)
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+func (h *Handler) listAdjustments(w http.ResponseWriter, r *http.Request) {
+	items, err := h.accounts.Adjustments(r.Context(), chi.URLParam(r, "user_id"))
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// adjust — ручная корректировка баланса; amount со знаком, оператор и причина пишутся рядом с проводкой
+func (h *Handler) adjust(w http.ResponseWriter, r *http.Request) {
+	operator := r.Header.Get(OperatorHeader)
+	if operator == "" {
+		writeProblem(w, r, http.StatusUnauthorized, CodeOperatorRequired, OperatorHeader+" header required")
+		return
+	}
+	type req struct {
+		Amount int64  `json:"amount"`
+		Reason string `json:"reason"`
+	}
+	var body req
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+		return
+	}
+	if body.Amount == 0 || strings.TrimSpace(body.Reason) == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "non-zero amount and reason required")
+		return
+	}
+	userID := chi.URLParam(r, "user_id")
+	adj, err := h.accounts.Adjust(r.Context(), userID, body.Amount, body.Reason, operator)
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("balance adjusted",
+		"user_id", userID, "amount", adj.Amount, "balance", adj.BalanceAfter, "operator_id", operator)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(adj)
+}
+
+// AdminHandler — карточка оплаты заказа для бэк-офиса: платёж, события payments и что дошло в inbox; возврат денег
+type AdminHandler struct {
+	svc      *payment.Service
+	payments *payment.Repository
+	outbox   *outbox.Repository
+	inbox    *inbox.Repository
+}
+
+func NewAdminHandler(svc *payment.Service, payments *payment.Repository, outboxRepo *outbox.Repository, inboxRepo *inbox.Repository) *AdminHandler {
+	return &AdminHandler{svc: svc, payments: payments, outbox: outboxRepo, inbox: inboxRepo}
+}
+
+func (h *AdminHandler) Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/{order_id}", h.get)
+	r.Post("/{order_id}/refund", h.refund)
+	return r
+}
+
+// get: /admin/payments/{order_id}?message_id=...&message_id=... — message_id это ID строк outbox orders,
+// по ним видно, какие события заказа payments уже принял. Платежа может ещё не быть — тогда payment: null.
+func (h *AdminHandler) get(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "order_id must be an integer")
+		return
+	}
+	var messageIDs []uuid.UUID
+	for _, raw := range r.URL.Query()["message_id"] {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidation, "invalid message_id")
+			return
+		}
+		messageIDs = append(messageIDs, id)
+	}
+
+	var pay *payment.Payment
+	p, err := h.payments.Get(r.Context(), orderID)
+	switch {
+	case err == nil:
+		pay = &p
+	case !errors.Is(err, sql.ErrNoRows):
+		dbError(w, r, err)
+		return
+	}
+	records := []outbox.Record{}
+	err = h.outbox.Dump(r.Context(), outbox.Filter{OrderID: orderID}, func(rec outbox.Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	received, err := h.inbox.Find(r.Context(), messageIDs)
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"payment": pay,
+		"outbox":  records,
+		"inbox":   received,
+	})
+}
+
+func (h *AdminHandler) refund(w http.ResponseWriter, r *http.Request) {
+	operator := r.Header.Get(OperatorHeader)
+	if operator == "" {
+		writeProblem(w, r, http.StatusUnauthorized, CodeOperatorRequired, OperatorHeader+" header required")
+		return
+	}
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "order_id must be an integer")
+		return
+	}
+	type req struct {
+		Reason string `json:"reason"`
+	}
+	var body req
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+		return
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "reason required")
+		return
+	}
+
+	p, err := h.svc.Refund(r.Context(), orderID, body.Reason, operator)
+	switch {
+	case errors.Is(err, payment.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodePaymentNotFound, err.Error())
+		return
+	case errors.Is(err, payment.ErrNotRefundable):
+		writeProblem(w, r, http.StatusConflict, CodeNotRefundable, err.Error())
+		return
+	case err != nil:
+		writeAccountError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("payment refunded",
+		"order_id", orderID, "user_id", p.UserID, "amount", p.Amount, "operator_id", operator)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -2,18 +2,25 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/example/webshop/payments/internal/account"
+	"github.com/example/webshop/payments/internal/logging"
+	"github.com/example/webshop/payments/internal/payment"
+	"github.com/example/webshop/payments/internal/risk"
 )
 
 type Handler struct {
-	accounts *account.Repository
+	accounts *account.Service
+	payments *payment.Service
+	risk     *risk.Repository
 }
 
-func NewHandler(accounts *account.Repository) *Handler {
-	return &Handler{accounts: accounts}
+func NewHandler(accounts *account.Service, payments *payment.Service, riskRepo *risk.Repository) *Handler {
+	return &Handler{accounts: accounts, payments: payments, risk: riskRepo}
 }
 
 func (h *Handler) Router() *chi.Mux {
@@ -21,6 +28,20 @@ func (h *Handler) Router() *chi.Mux {
 	r.Post("/accounts", h.createAccount)
 	r.Post("/accounts/deposit", h.deposit)
 	r.Get("/accounts/{user_id}/balance", h.balance)
+
+	r.Get("/admin/accounts", h.listAccounts)
+	r.Route("/admin/accounts/{user_id}", func(r chi.Router) {
+		r.Post("/freeze", h.changeStatus(account.ActionFreeze))
+		r.Post("/unfreeze", h.changeStatus(account.ActionUnfreeze))
+		r.Post("/close", h.changeStatus(account.ActionClose))
+		r.Post("/reopen", h.changeStatus(account.ActionReopen))
+		r.Get("/limits", h.getLimits)
+		r.Put("/limits", h.putLimits)
+		r.Get("/adjustments", h.listAdjustments)
+		r.Post("/adjustments", h.adjust)
+	})
+	r.Get("/admin/reviews", h.listReviews)
+	r.Post("/admin/reviews/{order_id}/resolve", h.resolveReview)
 	return r
 }
 
@@ -30,20 +51,20 @@ func (h *Handler) createAccount(w http.ResponseWriter, r *http.Request) {
 	}
 	var body req
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
 		return
 	}
 	if body.UserID == "" {
-		http.Error(w, "user_id required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "user_id required")
 		return
 	}
-	created, err := h.accounts.CreateIfAbsent(r.Context(), body.UserID)
+	created, err := h.accounts.Create(r.Context(), body.UserID)
 	if err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
+		dbError(w, r, err)
 		return
 	}
 	if !created {
-		http.Error(w, "account exists", http.StatusConflict)
+		writeProblem(w, r, http.StatusConflict, CodeAccountExists, "account already exists")
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -56,16 +77,16 @@ func (h *Handler) deposit(w http.ResponseWriter, r *http.Request) {
 	}
 	var body req
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
 		return
 	}
 	if body.UserID == "" || body.Amount <= 0 {
-		http.Error(w, "user_id and positive amount required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "user_id and positive amount required")
 		return
 	}
 	balance, err := h.accounts.Deposit(r.Context(), body.UserID, body.Amount)
 	if err != nil {
-		http.Error(w, "account not found", http.StatusNotFound)
+		writeAccountError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -77,15 +98,160 @@ func (h *Handler) deposit(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) balance(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "user_id")
-	balance, err := h.accounts.Balance(r.Context(), userID)
+	acc, err := h.accounts.Get(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "account not found", http.StatusNotFound)
+		writeAccountError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
 		"user_id": userID,
-		"balance": balance,
+		"balance": acc.Balance,
+		"status":  acc.Status,
 	})
 }
 
+func (h *Handler) changeStatus(action account.Action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// оператора ставит gateway по токену; без него смену статуса не в чем будет разбирать
+		operator := r.Header.Get(OperatorHeader)
+		if operator == "" {
+			writeProblem(w, r, http.StatusUnauthorized, CodeOperatorRequired, OperatorHeader+" header required")
+			return
+		}
+		type req struct {
+			Reason string `json:"reason"`
+		}
+		var body req
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+			return
+		}
+		if body.Reason == "" {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidation, "reason required")
+			return
+		}
+		userID := chi.URLParam(r, "user_id")
+		acc, err := h.accounts.ChangeStatus(r.Context(), userID, action, body.Reason, operator)
+		if err != nil {
+			writeAccountError(w, r, err)
+			return
+		}
+		logging.FromContext(r.Context()).Info("account status changed",
+			"user_id", userID, "action", string(action), "status", acc.Status, "operator_id", operator)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(acc)
+	}
+}
+
+func (h *Handler) getLimits(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "user_id")
+	if _, err := h.accounts.Get(r.Context(), userID); err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	limits, err := h.risk.Limits(r.Context(), userID)
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(limits)
+}
+
+func (h *Handler) putLimits(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "user_id")
+	var body risk.Limits
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+		return
+	}
+	if body.MaxSinglePayment < 0 || body.DailyLimit < 0 || body.MonthlyLimit < 0 ||
+		body.MaxOrdersPerHour < 0 || body.ReviewAmount < 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "limits must not be negative")
+		return
+	}
+	if _, err := h.accounts.Get(r.Context(), userID); err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	body.UserID = userID
+	if err := h.risk.SaveLimits(r.Context(), body); err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (h *Handler) listReviews(w http.ResponseWriter, r *http.Request) {
+	items, err := h.risk.ListReviews(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		dbError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// resolveReview — REJECTED возвращает деньги за заказ, поэтому нужен оператор: он попадёт в корректировку счёта
+func (h *Handler) resolveReview(w http.ResponseWriter, r *http.Request) {
+	operator := r.Header.Get(OperatorHeader)
+	if operator == "" {
+		writeProblem(w, r, http.StatusUnauthorized, CodeOperatorRequired, OperatorHeader+" header required")
+		return
+	}
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "order_id must be an integer")
+		return
+	}
+	type req struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	var body req
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+		return
+	}
+	if body.Status != risk.ReviewApproved && body.Status != risk.ReviewRejected {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "status must be APPROVED or REJECTED")
+		return
+	}
+	err = h.payments.ResolveReview(r.Context(), orderID, body.Status, body.Note, operator)
+	switch {
+	case errors.Is(err, payment.ErrNoReview):
+		writeProblem(w, r, http.StatusNotFound, CodeReviewNotFound, err.Error())
+		return
+	case errors.Is(err, payment.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodePaymentNotFound, err.Error())
+		return
+	case err != nil:
+		writeAccountError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("review resolved",
+		"order_id", orderID, "status", body.Status, "operator_id", operator)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAccountError раскладывает доменные ошибки счёта по HTTP-кодам
+func writeAccountError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, account.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeAccountNotFound, err.Error())
+	case errors.Is(err, account.ErrFrozen):
+		writeProblem(w, r, http.StatusConflict, CodeAccountFrozen, err.Error())
+	case errors.Is(err, account.ErrClosed):
+		writeProblem(w, r, http.StatusConflict, CodeAccountClosed, err.Error())
+	case errors.Is(err, account.ErrNonZeroBalance):
+		writeProblem(w, r, http.StatusConflict, CodeNonZeroBalance, err.Error())
+	case errors.Is(err, account.ErrInvalidTransition):
+		writeProblem(w, r, http.StatusConflict, CodeInvalidTransition, err.Error())
+	case errors.Is(err, account.ErrNegativeBalance):
+		writeProblem(w, r, http.StatusConflict, CodeNegativeBalance, err.Error())
+	default:
+		dbError(w, r, err)
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/example/webshop/payments/internal/logging"
+)
+
+// RequestTimeout вешает дедлайн на контекст запроса. Хендлеры передают контекст в БД,
+// так что медленный запрос отпускает соединение, а не держит пул.
+func RequestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// dbError — 504 на дедлайн запроса и серверные таймауты Postgres, остальное 500.
+// Текст ошибки БД клиенту не отдаём, он остаётся в логе.
+func dbError(w http.ResponseWriter, r *http.Request, err error) {
+	if isTimeout(err) {
+		writeProblem(w, r, http.StatusGatewayTimeout, CodeDBTimeout, "database did not respond in time")
+		return
+	}
+	logging.FromContext(r.Context()).Error("db error", "method", r.Method, "path", r.URL.Path, "err", err)
+	writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "")
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	// 57014 — statement_timeout, 55P03 — lock_timeout
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "57014" || pgErr.Code == "55P03")
+}
+
+// DBStats отдаёт состояние пула соединений: сколько занято, сколько ждали свободного
+func DBStats(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := db.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"max_open_connections": s.MaxOpenConnections,
+			"open_connections":     s.OpenConnections,
+			"in_use":               s.InUse,
+			"idle":                 s.Idle,
+			"wait_count":           s.WaitCount,
+			"wait_duration_ms":     s.WaitDuration.Milliseconds(),
+			"max_idle_closed":      s.MaxIdleClosed,
+			"max_idle_time_closed": s.MaxIdleTimeClosed,
+			"max_lifetime_closed":  s.MaxLifetimeClosed,
+		})
+	}
+}
+
+// Healthz — инстанс жив, если отвечает база; по нему gateway выводит инстанс из ротации
+func Healthz(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			writeProblem(w, r, http.StatusServiceUnavailable, CodeDBUnavailable, "")
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
@@ -3,10 +3,17 @@ package inbox
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// Record — запись инбокса в формате выгрузки
+type Record struct {
+	MessageID  uuid.UUID `json:"message_id"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
 // DBTX — маленький общий интерфейс под транзакцию/DB
 type DBTX interface {
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
@@ -33,3 +40,84 @@ func (r *Repository) TryInsert(ctx context.Context, tx DBTX, id uuid.UUID) (bool
 	return rows > 0, nil
 }
 
+// DeleteOlderThan чистит старые записи инбокса пачкой. Дедуп держится только в окне retention,
+// дальше от двойного списания страхует уникальный order_id в payments.
+func (r *Repository) DeleteOlderThan(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM inbox
+		WHERE message_id IN (
+			SELECT message_id FROM inbox
+			WHERE received_at < now() - $1::bigint * interval '1 second'
+			ORDER BY received_at
+			LIMIT $2
+		)
+	`, int64(olderThan.Seconds()), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Dump стримит записи инбокса за период; нулевые границы — без ограничения
+func (r *Repository) Dump(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT message_id, received_at
+		FROM inbox
+		WHERE ($1::timestamp IS NULL OR received_at >= $1)
+		  AND ($2::timestamp IS NULL OR received_at < $2)
+		ORDER BY received_at
+	`, nullTime(from), nullTime(to))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec Record
+		if err := rows.Scan(&rec.MessageID, &rec.ReceivedAt); err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Find — какие из сообщений уже приняты; по ID из outbox orders видно, дошло ли событие заказа
+func (r *Repository) Find(ctx context.Context, ids []uuid.UUID) ([]Record, error) {
+	res := []Record{}
+	if len(ids) == 0 {
+		return res, nil
+	}
+	strIDs := make([]string, len(ids))
+	for i, id := range ids {
+		strIDs[i] = id.String()
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT message_id, received_at
+		FROM inbox
+		WHERE message_id = ANY($1::uuid[])
+		ORDER BY received_at
+	`, strIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec Record
+		if err := rows.Scan(&rec.MessageID, &rec.ReceivedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
+
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
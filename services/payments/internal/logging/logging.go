@@ -0,0 +1,123 @@
+// Package logging — slog-логгер сервиса и request ID, который ходит через контекст.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Setup ставит slog по умолчанию: level — debug|info|warn|error, format — json|text.
+// Заодно перенаправляет стандартный log, чтобы случайные log.Printf тоже шли в общий поток.
+func Setup(service, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	logger := slog.New(h).With("service", service)
+	slog.SetDefault(logger)
+	return logger
+}
+
+func parseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+type ctxKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// FromContext — логгер по умолчанию с request_id, если он есть в контексте
+func FromContext(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+func NewRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Middleware берёт X-Request-ID из запроса (или выдаёт новый), кладёт его в контекст
+// и в ответ и пишет access-лог
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = NewRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		ctx := WithRequestID(r.Context(), id)
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, requestID: id, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		if !sw.wroteHeader {
+			sw.WriteHeader(http.StatusOK)
+		}
+
+		FromContext(ctx).Info("http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"bytes", sw.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	requestID   string
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+// WriteHeader запоминает статус для access-лога и ставит X-Request-ID в ответ
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+		w.Header().Set(RequestIDHeader, w.requestID)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap нужен http.ResponseController (Flush у стриминговых ответов)
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
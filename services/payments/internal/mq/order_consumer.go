@@ -3,48 +3,125 @@ package mq
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/example/webshop/payments/internal/deadletter"
+	"github.com/example/webshop/payments/internal/events"
 	"github.com/example/webshop/payments/internal/payment"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// ConsumerOptions — очередь, prefetch на канал, размер пула воркеров и повторы обработки
+type ConsumerOptions struct {
+	Queue    string
+	Prefetch int
+	Workers  int
+	Retry    RetryPolicy
+}
+
 type OrderConsumer struct {
 	svc     *payment.Service
+	dead    *deadletter.Repository
 	channel *amqp.Channel
+	opts    ConsumerOptions
+}
+
+func NewOrderConsumer(svc *payment.Service, dead *deadletter.Repository, ch *amqp.Channel, opts ConsumerOptions) *OrderConsumer {
+	return &OrderConsumer{svc: svc, dead: dead, channel: ch, opts: opts}
 }
 
-func NewOrderConsumer(svc *payment.Service, ch *amqp.Channel) *OrderConsumer {
-	return &OrderConsumer{svc: svc, channel: ch}
+type paymentJob struct {
+	delivery amqp.Delivery
+	task     payment.PaymentTask
 }
 
+// Run читает order.payments и раздаёт задачи пулу воркеров по user_id:
+// задачи одного пользователя дерутся за один и тот же лок баланса, их держим по порядку
 func (c *OrderConsumer) Run(ctx context.Context) error {
-	msgs, err := c.channel.Consume("order.payments", "payments-worker", false, false, false, false, nil)
+	if err := c.channel.Qos(c.opts.Prefetch, 0, false); err != nil {
+		return err
+	}
+	msgs, err := c.channel.Consume(c.opts.Queue, "payments-worker", false, false, false, false, nil)
 	if err != nil {
 		return err
 	}
+
+	pool := newKeyedPool(c.opts.Workers, c.opts.Prefetch, func(j paymentJob) { c.handle(ctx, j) })
+	defer pool.Close()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case d, ok := <-msgs:
 			if !ok {
-				log.Printf("order.payments channel closed")
+				slog.Warn("consumer channel closed", "queue", c.opts.Queue)
 				return nil
 			}
-			var task payment.PaymentTask
-			if err := json.Unmarshal(d.Body, &task); err != nil {
-				log.Printf("bad payment task: %v", err)
-				_ = d.Nack(false, false)
+			task, err := decodeTask(d.Body)
+			if err != nil {
+				deadLetter(ctx, c.dead, c.opts.Queue, d, fmt.Errorf("bad payment task: %w", err))
 				continue
 			}
-			if err := c.svc.ProcessPayment(ctx, task); err != nil {
-				log.Printf("process payment: %v", err)
+			if !pool.Submit(ctx, task.UserID, paymentJob{delivery: d, task: task}) {
 				_ = d.Nack(false, true)
-				continue
+				return nil
 			}
-			_ = d.Ack(false)
 		}
 	}
 }
 
+// decodeTask разбирает конверт по type+version; тело без конверта — формат до перехода на exchange
+func decodeTask(body []byte) (payment.PaymentTask, error) {
+	var task payment.PaymentTask
+	env, err := events.Decode(body)
+	if errors.Is(err, events.ErrNotEnvelope) {
+		err = json.Unmarshal(body, &task)
+		return task, err
+	}
+	if err != nil {
+		return task, err
+	}
+
+	switch {
+	case env.Type == events.TypeOrderCreated && env.Version == 1:
+		var data events.OrderCreatedV1
+		if err := json.Unmarshal(env.Data, &data); err != nil {
+			return task, err
+		}
+		// ID конверта — ключ дедупа в inbox
+		task = payment.PaymentTask{
+			MessageID:     env.ID,
+			OrderID:       data.OrderID,
+			UserID:        data.UserID,
+			Amount:        data.Amount,
+			CorrelationID: env.CorrelationID,
+		}
+		return task, nil
+	default:
+		return task, fmt.Errorf("unsupported event %s", env.RoutingKey())
+	}
+}
+
+func (c *OrderConsumer) handle(ctx context.Context, j paymentJob) {
+	logger := slog.With(
+		"message_id", j.task.MessageID,
+		"order_id", j.task.OrderID,
+		"user_id", j.task.UserID,
+		"correlation_id", j.task.CorrelationID,
+		"redelivered", j.delivery.Redelivered,
+	)
+	start := time.Now()
+	attempts, err := c.opts.Retry.Do(ctx, func() error {
+		return c.svc.ProcessPayment(ctx, j.task)
+	})
+	if err != nil {
+		retryExhausted(ctx, logger, c.dead, c.opts.Queue, j.delivery, attempts, err)
+		return
+	}
+	_ = j.delivery.Ack(false)
+	logger.Info("payment task processed", "amount", j.task.Amount, "duration_ms", time.Since(start).Milliseconds())
+}
@@ -3,67 +3,156 @@ package mq
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/example/webshop/payments/internal/events"
 	"github.com/example/webshop/payments/internal/outbox"
+	"github.com/jackc/pgx/v5"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type OutboxPublisher struct {
-	db      *sql.DB
-	repo    *outbox.Repository
-	channel *amqp.Channel
-	limit   int
+	db       *sql.DB
+	repo     *outbox.Repository
+	channel  *amqp.Channel
+	dbURL    string
+	interval time.Duration
+	limit    int
+	// legacyQueue — куда слать строки без конверта (default exchange)
+	legacyQueue string
 }
 
-func NewOutboxPublisher(db *sql.DB, repo *outbox.Repository, ch *amqp.Channel) *OutboxPublisher {
-	return &OutboxPublisher{db: db, repo: repo, channel: ch, limit: 20}
+func NewOutboxPublisher(db *sql.DB, repo *outbox.Repository, ch *amqp.Channel, dbURL string, interval time.Duration, limit int, legacyQueue string) *OutboxPublisher {
+	return &OutboxPublisher{db: db, repo: repo, channel: ch, dbURL: dbURL, interval: interval, limit: limit, legacyQueue: legacyQueue}
 }
 
+// Run дренирует outbox по NOTIFY сразу после коммита; тикер остаётся страховкой,
+// если уведомление потерялось или LISTEN-соединение отвалилось
 func (p *OutboxPublisher) Run(ctx context.Context) {
-	ticker := time.NewTicker(time.Second)
+	wake := make(chan struct{}, 1)
+	go p.listen(ctx, wake)
+
+	ticker := time.NewTicker(p.interval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := p.publishBatch(ctx); err != nil {
-				log.Printf("payments outbox publish error: %v", err)
-			}
+		case <-wake:
 		}
+		p.drain(ctx)
 	}
 }
 
-func (p *OutboxPublisher) publishBatch(ctx context.Context) error {
-	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{})
+// drain публикует пачки, пока они приходят полными
+func (p *OutboxPublisher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := p.publishBatch(ctx)
+		if err != nil {
+			slog.Error("outbox publish failed", "err", err)
+			return
+		}
+		if n < p.limit {
+			return
+		}
+	}
+}
+
+func (p *OutboxPublisher) listen(ctx context.Context, wake chan<- struct{}) {
+	for ctx.Err() == nil {
+		err := p.listenOnce(ctx, wake)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("outbox listen failed", "err", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (p *OutboxPublisher) listenOnce(ctx context.Context, wake chan<- struct{}) error {
+	conn, err := pgx.Connect(ctx, p.dbURL)
 	if err != nil {
 		return err
 	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+outbox.NotifyChannel); err != nil {
+		return err
+	}
+	// Пока не слушали, уведомления могли пролететь мимо — дёргаем дренаж сразу
+	signal(wake)
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		signal(wake)
+	}
+}
+
+// signal не блокируется: один ожидающий сигнал и так вызовет полный дренаж
+func signal(wake chan<- struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *OutboxPublisher) publishBatch(ctx context.Context) (int, error) {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
 	defer tx.Rollback()
 
 	msgs, err := p.repo.FetchPending(ctx, tx, p.limit)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if len(msgs) == 0 {
-		return tx.Commit()
+		return 0, tx.Commit()
 	}
 
 	for _, m := range msgs {
-		if err := p.channel.PublishWithContext(ctx, "", "payment.status", false, false, amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         m.Payload,
-			MessageId:    m.ID.String(),
-			DeliveryMode: amqp.Persistent,
-		}); err != nil {
-			return err
+		exchange, key, pub := publishing(m, p.legacyQueue)
+		if err := p.channel.PublishWithContext(ctx, exchange, key, false, false, pub); err != nil {
+			return 0, err
 		}
 		if err := p.repo.MarkPublished(ctx, tx, m.ID); err != nil {
-			return err
+			return 0, err
 		}
+		slog.Info("outbox message published",
+			"message_id", m.ID.String(),
+			"exchange", exchange,
+			"routing_key", key,
+			"correlation_id", pub.CorrelationId,
+		)
 	}
-	return tx.Commit()
+
+	return len(msgs), tx.Commit()
 }
 
+// publishing собирает сообщение из строки outbox: конверт уходит в topic exchange по своему ключу,
+// строки, записанные до конверта, — как раньше, через default exchange прямо в очередь
+func publishing(m outbox.Message, legacyQueue string) (string, string, amqp.Publishing) {
+	pub := amqp.Publishing{
+		ContentType:  "application/json",
+		Body:         m.Payload,
+		MessageId:    m.ID.String(),
+		DeliveryMode: amqp.Persistent,
+	}
+	env, err := events.Decode(m.Payload)
+	if err != nil {
+		return "", legacyQueue, pub
+	}
+	pub.Type = env.Type
+	pub.CorrelationId = env.CorrelationID
+	pub.AppId = env.Producer
+	pub.Timestamp = env.Timestamp
+	pub.Headers = amqp.Table{"version": int32(env.Version)}
+	return events.Exchange, env.RoutingKey(), pub
+}
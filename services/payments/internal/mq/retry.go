@@ -0,0 +1,32 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy — сколько раз повторить обработку сообщения на месте; после MaxAttempts оно уходит в dead_letters
+// (в очередь возвращается только при остановке).
+// Пауза удваивается от InitialBackoff до MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Do возвращает последнюю ошибку и число сделанных попыток
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) (int, error) {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts {
+			return attempt, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, p.MaxBackoff)
+	}
+}
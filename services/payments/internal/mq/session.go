@@ -0,0 +1,109 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNotConnected — брокер сейчас недоступен, сессия переподключается
+var ErrNotConnected = errors.New("rabbitmq is not connected")
+
+// Session держит соединение с RabbitMQ: поднимает топологию, запускает воркеров на свежем канале
+// и переподключается, если брокер рестартанул или оборвал соединение. Пока брокера нет,
+// сообщения копятся в outbox и уедут после переподключения.
+type Session struct {
+	url     string
+	delay   time.Duration
+	declare func(*amqp.Channel) error
+
+	mu sync.RWMutex
+	ch *amqp.Channel
+}
+
+func NewSession(url string, reconnectDelay time.Duration, declare func(*amqp.Channel) error) *Session {
+	return &Session{url: url, delay: reconnectDelay, declare: declare}
+}
+
+// Channel — текущий канал; между переподключениями вернёт ErrNotConnected
+func (s *Session) Channel() (*amqp.Channel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ch == nil {
+		return nil, ErrNotConnected
+	}
+	return s.ch, nil
+}
+
+// Run держит сессию до отмены ctx. start запускает воркеров на канале и должен вернуться,
+// когда отменят его контекст; если start вернулся сам, сессия пересоздаётся.
+func (s *Session) Run(ctx context.Context, start func(ctx context.Context, ch *amqp.Channel)) {
+	for ctx.Err() == nil {
+		if err := s.serve(ctx, start); err != nil && ctx.Err() == nil {
+			slog.Warn("rabbit session lost", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.delay):
+		}
+	}
+}
+
+func (s *Session) serve(ctx context.Context, start func(ctx context.Context, ch *amqp.Channel)) error {
+	conn, err := amqp.Dial(s.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := s.declare(ch); err != nil {
+		return err
+	}
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	s.set(ch)
+	defer s.set(nil)
+	slog.Info("rabbit session established")
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		start(sctx, ch)
+	}()
+
+	var amqpErr *amqp.Error
+	select {
+	case <-ctx.Done():
+	case <-done:
+	case amqpErr = <-connClosed:
+	case amqpErr = <-chClosed:
+	}
+	cancel()
+	<-done
+	if amqpErr != nil {
+		return amqpErr
+	}
+	if ctx.Err() == nil {
+		return errors.New("session workers stopped")
+	}
+	return nil
+}
+
+func (s *Session) set(ch *amqp.Channel) {
+	s.mu.Lock()
+	s.ch = ch
+	s.mu.Unlock()
+}
@@ -0,0 +1,39 @@
+package mq
+
+import (
+	"github.com/example/webshop/payments/internal/events"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Topology — имена очередей сервисов; сами имена приходят из конфига
+type Topology struct {
+	OrderPayments string
+	PaymentStatus string
+}
+
+func (t Topology) Queues() []string {
+	return []string{t.OrderPayments, t.PaymentStatus}
+}
+
+// Declare поднимает общий topic exchange и привязывает к нему очереди сервисов.
+// Биндинг по маске версии: новые версии доезжают до консьюмера, а он сам решает, умеет ли их
+func (t Topology) Declare(ch *amqp.Channel) error {
+	if err := ch.ExchangeDeclare(events.Exchange, "topic", true, false, false, false, nil); err != nil {
+		return err
+	}
+	bindings := map[string][]string{
+		t.OrderPayments: {"orders.created.*"},
+		t.PaymentStatus: {"payments.completed.*", "payments.cancelled.*", "payments.refunded.*"},
+	}
+	for queue, keys := range bindings {
+		if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := ch.QueueBind(queue, key, events.Exchange, false, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package mq
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// keyedPool раскидывает задачи по воркерам по хешу ключа: один ключ всегда попадает
+// в одного воркера и обрабатывается строго по порядку, разные ключи идут параллельно
+type keyedPool[T any] struct {
+	queues []chan T
+	wg     sync.WaitGroup
+}
+
+func newKeyedPool[T any](workers, buffer int, handle func(T)) *keyedPool[T] {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &keyedPool[T]{queues: make([]chan T, workers)}
+	for i := range p.queues {
+		q := make(chan T, buffer)
+		p.queues[i] = q
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range q {
+				handle(job)
+			}
+		}()
+	}
+	return p
+}
+
+// Submit ждёт места в очереди воркера; false — контекст отменили раньше
+func (p *keyedPool[T]) Submit(ctx context.Context, key string, job T) bool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	q := p.queues[h.Sum32()%uint32(len(p.queues))]
+	select {
+	case q <- job:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Close дожидается, пока воркеры доедят уже принятые задачи
+func (p *keyedPool[T]) Close() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
@@ -3,6 +3,10 @@ package outbox
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,12 +18,64 @@ type DBTX interface {
 	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 }
 
+// NotifyChannel — канал LISTEN/NOTIFY, по которому паблишер узнаёт о новых строках
+const NotifyChannel = "payments_outbox"
+
 type Message struct {
 	ID      uuid.UUID
 	Payload []byte
 	Created time.Time
 }
 
+// ErrEmptyFilter — переопубликовать "всё подряд" не даём, нужен хоть один критерий
+var ErrEmptyFilter = errors.New("empty outbox filter")
+
+// Filter выбирает строки outbox для replay/выгрузки; пустые поля не участвуют
+type Filter struct {
+	IDs     []uuid.UUID
+	OrderID int64
+	From    time.Time
+	To      time.Time
+}
+
+func (f Filter) IsZero() bool {
+	return len(f.IDs) == 0 && f.OrderID == 0 && f.From.IsZero() && f.To.IsZero()
+}
+
+func (f Filter) where() (string, []any) {
+	conds := []string{"TRUE"}
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if len(f.IDs) > 0 {
+		ids := make([]string, len(f.IDs))
+		for i, id := range f.IDs {
+			ids[i] = id.String()
+		}
+		add("id = ANY($%d::uuid[])", ids)
+	}
+	if f.OrderID != 0 {
+		add("COALESCE(payload->'data'->>'order_id', payload->>'order_id')::bigint = $%d", f.OrderID)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To.UTC())
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Record — строка outbox в формате выгрузки (JSON lines), её же принимает inject
+type Record struct {
+	ID          uuid.UUID       `json:"id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+	PublishedAt *time.Time      `json:"published_at,omitempty"`
+}
+
 type Repository struct {
 	db *sql.DB
 }
@@ -32,6 +88,15 @@ func (r *Repository) Insert(ctx context.Context, tx DBTX, id uuid.UUID, payload
 	_, err := tx.ExecContext(ctx, `
 		INSERT INTO outbox(id, payload) VALUES ($1, $2)
 	`, id, payload)
+	if err != nil {
+		return err
+	}
+	return r.Notify(ctx, tx)
+}
+
+// Notify будит паблишер; в транзакции Postgres доставит уведомление только после коммита
+func (r *Repository) Notify(ctx context.Context, tx DBTX) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, NotifyChannel)
 	return err
 }
 
@@ -65,3 +130,108 @@ func (r *Repository) MarkPublished(ctx context.Context, tx DBTX, id uuid.UUID) e
 	return err
 }
 
+// DeletePublished сносит пачку опубликованных сообщений старше retention, возвращает сколько удалили
+func (r *Repository) DeletePublished(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM outbox
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at < now() - $1::bigint * interval '1 second'
+			ORDER BY published_at
+			LIMIT $2
+		)
+	`, int64(olderThan.Seconds()), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ArchivePublished переносит пачку в outbox_archive одним запросом — без окна, где строки нет нигде
+func (r *Repository) ArchivePublished(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		WITH batch AS (
+			SELECT id FROM outbox
+			WHERE published_at < now() - $1::bigint * interval '1 second'
+			ORDER BY published_at
+			LIMIT $2
+		), moved AS (
+			DELETE FROM outbox o USING batch b
+			WHERE o.id = b.id
+			RETURNING o.id, o.payload, o.published_at, o.created_at
+		)
+		INSERT INTO outbox_archive(id, payload, published_at, created_at)
+		SELECT id, payload, published_at, created_at FROM moved
+	`, int64(olderThan.Seconds()), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Backlog — неопубликованный хвост outbox: сколько строк ждут паблишера и с какого времени
+type Backlog struct {
+	Pending         int64      `json:"pending"`
+	OldestCreatedAt *time.Time `json:"oldest_created_at"`
+}
+
+func (r *Repository) Backlog(ctx context.Context) (Backlog, error) {
+	var b Backlog
+	err := r.db.QueryRowContext(ctx, `
+		SELECT count(*), min(created_at) FROM outbox WHERE published_at IS NULL
+	`).Scan(&b.Pending, &b.OldestCreatedAt)
+	return b, err
+}
+
+// Requeue сбрасывает published_at, и паблишер отправит строки заново с теми же ID
+func (r *Repository) Requeue(ctx context.Context, f Filter) (int64, error) {
+	if f.IsZero() {
+		return 0, ErrEmptyFilter
+	}
+	where, args := f.where()
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE outbox SET published_at = NULL WHERE published_at IS NOT NULL AND `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if n > 0 {
+		if err := r.Notify(ctx, tx); err != nil {
+			return 0, err
+		}
+	}
+	return n, tx.Commit()
+}
+
+// Dump стримит строки по фильтру в порядке создания
+func (r *Repository) Dump(ctx context.Context, f Filter, fn func(Record) error) error {
+	where, args := f.where()
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, payload, created_at, published_at
+		FROM outbox
+		WHERE `+where+`
+		ORDER BY created_at
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec Record
+		var payload []byte
+		if err := rows.Scan(&rec.ID, &payload, &rec.CreatedAt, &rec.PublishedAt); err != nil {
+			return err
+		}
+		rec.Payload = payload
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
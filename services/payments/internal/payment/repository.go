@@ -3,6 +3,7 @@ package payment
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 // DBTX — общий интерфейс для DB и tx, без лишнего
@@ -14,6 +15,16 @@ type DBTX interface {
 const (
 	StatusFinished  = "FINISHED"
 	StatusCancelled = "CANCELLED"
+	// StatusRefunded — деньги за FINISHED-платёж вернули на счёт
+	StatusRefunded = "REFUNDED"
+)
+
+// Коды причин отмены; лимитные коды приходят из risk
+const (
+	ReasonAccountNotFound   = "ACCOUNT_NOT_FOUND"
+	ReasonAccountFrozen     = "ACCOUNT_FROZEN"
+	ReasonAccountClosed     = "ACCOUNT_CLOSED"
+	ReasonInsufficientFunds = "INSUFFICIENT_FUNDS"
 )
 
 type Repository struct {
@@ -36,12 +47,48 @@ func (r *Repository) Exists(ctx context.Context, tx DBTX, orderID int64) (bool,
 	return true, nil
 }
 
-func (r *Repository) Insert(ctx context.Context, tx DBTX, orderID int64, userID string, amount int64, status string) error {
+func (r *Repository) Insert(ctx context.Context, tx DBTX, orderID int64, userID string, amount int64, status, reasonCode, reason string) error {
 	_, err := tx.ExecContext(ctx, `
-		INSERT INTO payments(order_id, user_id, amount, status)
-		VALUES ($1,$2,$3,$4)
+		INSERT INTO payments(order_id, user_id, amount, status, reason_code, reason)
+		VALUES ($1,$2,$3,$4,NULLIF($5, ''),NULLIF($6, ''))
 		ON CONFLICT DO NOTHING
-	`, orderID, userID, amount, status)
+	`, orderID, userID, amount, status, reasonCode, reason)
 	return err
 }
 
+// Payment — результат оплаты заказа, как он лежит в payments
+type Payment struct {
+	OrderID    int64     `json:"order_id"`
+	UserID     string    `json:"user_id"`
+	Amount     int64     `json:"amount"`
+	Status     string    `json:"status"`
+	ReasonCode string    `json:"reason_code,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (r *Repository) Get(ctx context.Context, orderID int64) (Payment, error) {
+	return r.get(ctx, r.db, orderID, false)
+}
+
+// GetForUpdate лочит строку платежа, чтобы два возврата по одному заказу не прошли оба
+func (r *Repository) GetForUpdate(ctx context.Context, tx DBTX, orderID int64) (Payment, error) {
+	return r.get(ctx, tx, orderID, true)
+}
+
+func (r *Repository) get(ctx context.Context, q DBTX, orderID int64, forUpdate bool) (Payment, error) {
+	query := `
+		SELECT order_id, user_id, amount, status, COALESCE(reason_code, ''), COALESCE(reason, ''), created_at
+		FROM payments WHERE order_id=$1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var p Payment
+	err := q.QueryRowContext(ctx, query, orderID).Scan(&p.OrderID, &p.UserID, &p.Amount, &p.Status, &p.ReasonCode, &p.Reason, &p.CreatedAt)
+	return p, err
+}
+
+func (r *Repository) SetStatus(ctx context.Context, tx DBTX, orderID int64, status, reason string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE payments SET status=$1, reason=NULLIF($2, '') WHERE order_id=$3`, status, reason, orderID)
+	return err
+}
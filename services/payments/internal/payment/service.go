@@ -3,25 +3,38 @@ package payment
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/example/webshop/payments/internal/account"
+	"github.com/example/webshop/payments/internal/db"
+	"github.com/example/webshop/payments/internal/events"
 	"github.com/example/webshop/payments/internal/inbox"
+	"github.com/example/webshop/payments/internal/logging"
 	"github.com/example/webshop/payments/internal/outbox"
+	"github.com/example/webshop/payments/internal/risk"
+)
+
+var (
+	ErrNotFound      = errors.New("payment not found")
+	ErrNotRefundable = errors.New("only FINISHED payments can be refunded")
+	ErrNoReview      = errors.New("pending review not found")
 )
 
 type PaymentTask struct {
-	MessageID string `json:"message_id"`
-	OrderID   int64  `json:"order_id"`
-	UserID    string `json:"user_id"`
-	Amount    int64  `json:"amount"`
+	MessageID     string `json:"message_id"`
+	OrderID       int64  `json:"order_id"`
+	UserID        string `json:"user_id"`
+	Amount        int64  `json:"amount"`
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
 
-type PaymentResult struct {
-	OrderID int64  `json:"order_id"`
-	Status  string `json:"status"`
-	Reason  string `json:"reason,omitempty"`
+// TxTimeouts — statement_timeout и lock_timeout транзакции оплаты; 0 — без ограничения
+type TxTimeouts struct {
+	Statement time.Duration
+	Lock      time.Duration
 }
 
 type Service struct {
@@ -30,10 +43,13 @@ type Service struct {
 	payments   *Repository
 	inbox      *inbox.Repository
 	outboxRepo *outbox.Repository
+	risk       *risk.Engine
+	reviews    *risk.Repository
+	timeouts   TxTimeouts
 }
 
-func NewService(db *sql.DB, accounts *account.Repository, payments *Repository, inbox *inbox.Repository, outboxRepo *outbox.Repository) *Service {
-	return &Service{db: db, accounts: accounts, payments: payments, inbox: inbox, outboxRepo: outboxRepo}
+func NewService(db *sql.DB, accounts *account.Repository, payments *Repository, inbox *inbox.Repository, outboxRepo *outbox.Repository, riskEngine *risk.Engine, reviews *risk.Repository, timeouts TxTimeouts) *Service {
+	return &Service{db: db, accounts: accounts, payments: payments, inbox: inbox, outboxRepo: outboxRepo, risk: riskEngine, reviews: reviews, timeouts: timeouts}
 }
 
 // ProcessPayment — транзакционный инбокс+аутбокс с идемпотентностью, чтоб не ловить дубль списаний
@@ -49,6 +65,11 @@ func (s *Service) ProcessPayment(ctx context.Context, task PaymentTask) error {
 	}
 	defer tx.Rollback()
 
+	// Ждать лок счёта бесконечно нельзя: соединение из пула висит, а за ним очередь воркеров
+	if err := db.SetLocalTimeouts(ctx, tx, s.timeouts.Statement, s.timeouts.Lock); err != nil {
+		return err
+	}
+
 	// Дедуп по инбоксу — одно сообщение, один заход
 	ok, err := s.inbox.TryInsert(ctx, tx, msgID)
 	if err != nil {
@@ -68,32 +89,60 @@ func (s *Service) ProcessPayment(ctx context.Context, task PaymentTask) error {
 	}
 
 	status := StatusCancelled
-	reason := ""
+	reason, code := "", ""
+	var flags []string
 
-	balance, err := s.accounts.BalanceForUpdate(ctx, tx, task.UserID)
+	acc, err := s.accounts.GetForUpdate(ctx, tx, task.UserID)
 	if err == sql.ErrNoRows {
-		reason = "account not found"
+		reason, code = "account not found", ReasonAccountNotFound
 	} else if err != nil {
 		return err
-	} else if balance < task.Amount {
-		reason = "insufficient funds"
+	} else if err := account.CheckActive(acc); err != nil {
+		// Замороженный/закрытый счёт — отмена с понятной причиной, а не ретрай
+		reason, code = err.Error(), statusReasonCode(acc.Status)
 	} else {
-		if err := s.accounts.Deduct(ctx, tx, task.UserID, task.Amount); err != nil {
+		// Лимиты и fraud-правила считаем под тем же локом счёта, чтобы параллельные заказы не проскочили
+		verdict, err := s.risk.Evaluate(ctx, tx, task.OrderID, task.Amount, acc)
+		if err != nil {
 			return err
 		}
-		status = StatusFinished
+		if verdict.RejectCode != "" {
+			reason, code = verdict.RejectReason, verdict.RejectCode
+		} else if acc.Balance < task.Amount {
+			reason, code = "insufficient funds", ReasonInsufficientFunds
+		} else {
+			if err := s.accounts.Deduct(ctx, tx, task.UserID, task.Amount); err != nil {
+				return err
+			}
+			status = StatusFinished
+			flags = verdict.Flags
+		}
 	}
 
-	if err := s.payments.Insert(ctx, tx, task.OrderID, task.UserID, task.Amount, status); err != nil {
+	if err := s.payments.Insert(ctx, tx, task.OrderID, task.UserID, task.Amount, status, code, reason); err != nil {
 		return err
 	}
+	if len(flags) > 0 {
+		if err := s.reviews.InsertReview(ctx, tx, task.OrderID, task.UserID, task.Amount, flags); err != nil {
+			return err
+		}
+	}
 
+	eventType := events.TypePaymentCancelled
+	if status == StatusFinished {
+		eventType = events.TypePaymentCompleted
+	}
 	outID := uuid.New()
-	payload, _ := json.Marshal(PaymentResult{
-		OrderID: task.OrderID,
-		Status:  status,
-		Reason:  reason,
+	payload, err := events.New(outID, eventType, 1, task.CorrelationID, events.PaymentResultV1{
+		OrderID:    task.OrderID,
+		UserID:     task.UserID,
+		Status:     status,
+		Reason:     reason,
+		ReasonCode: code,
 	})
+	if err != nil {
+		return err
+	}
 	if err := s.outboxRepo.Insert(ctx, tx, outID, payload); err != nil {
 		return err
 	}
@@ -101,3 +150,119 @@ func (s *Service) ProcessPayment(ctx context.Context, task PaymentTask) error {
 	return tx.Commit()
 }
 
+// Refund возвращает деньги за оплаченный заказ: счёт пополняется корректировкой от имени оператора,
+// платёж становится REFUNDED, а orders узнаёт об этом из события payments.refunded
+func (s *Service) Refund(ctx context.Context, orderID int64, reason, operatorID string) (Payment, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return Payment{}, err
+	}
+	defer tx.Rollback()
+
+	if err := db.SetLocalTimeouts(ctx, tx, s.timeouts.Statement, s.timeouts.Lock); err != nil {
+		return Payment{}, err
+	}
+	p, err := s.refund(ctx, tx, orderID, reason, operatorID)
+	if err != nil {
+		return Payment{}, err
+	}
+	return p, tx.Commit()
+}
+
+// ResolveReview закрывает ручную проверку. REJECTED — это возврат денег тем же путём, что Refund,
+// в одной транзакции с проверкой: иначе отклонение ни на что бы не влияло
+func (s *Service) ResolveReview(ctx context.Context, orderID int64, status, note, operatorID string) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := db.SetLocalTimeouts(ctx, tx, s.timeouts.Statement, s.timeouts.Lock); err != nil {
+		return err
+	}
+	ok, err := s.reviews.ResolveReview(ctx, tx, orderID, status, note)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoReview
+	}
+	if status == risk.ReviewRejected {
+		reason := "risk review rejected"
+		if note != "" {
+			reason += ": " + note
+		}
+		// Проверка заводится только на оплаченный платёж; не FINISHED — оператор уже вернул деньги сам
+		if _, err := s.refund(ctx, tx, orderID, reason, operatorID); err != nil && !errors.Is(err, ErrNotRefundable) {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// refund — тело Refund внутри чужой транзакции: платёж, потом счёт
+func (s *Service) refund(ctx context.Context, tx *sql.Tx, orderID int64, reason, operatorID string) (Payment, error) {
+	p, err := s.payments.GetForUpdate(ctx, tx, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Payment{}, ErrNotFound
+	}
+	if err != nil {
+		return Payment{}, err
+	}
+	if p.Status != StatusFinished {
+		return Payment{}, ErrNotRefundable
+	}
+	// Сначала платёж, потом счёт: ProcessPayment оплаченную строку уже не трогает, дедлока с оплатой нет
+	acc, err := s.accounts.GetForUpdate(ctx, tx, p.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Payment{}, account.ErrNotFound
+	}
+	if err != nil {
+		return Payment{}, err
+	}
+	if acc.Status == account.StatusClosed {
+		return Payment{}, account.ErrClosed
+	}
+
+	balance, err := s.accounts.Credit(ctx, tx, p.UserID, p.Amount)
+	if err != nil {
+		return Payment{}, err
+	}
+	_, err = s.accounts.InsertAdjustment(ctx, tx, account.Adjustment{
+		UserID:       p.UserID,
+		Amount:       p.Amount,
+		BalanceAfter: balance,
+		Reason:       fmt.Sprintf("refund of order %d: %s", orderID, reason),
+		OperatorID:   operatorID,
+	})
+	if err != nil {
+		return Payment{}, err
+	}
+	if err := s.payments.SetStatus(ctx, tx, orderID, StatusRefunded, reason); err != nil {
+		return Payment{}, err
+	}
+
+	outID := uuid.New()
+	payload, err := events.New(outID, events.TypePaymentRefunded, 1, logging.RequestID(ctx), events.PaymentResultV1{
+		OrderID: orderID,
+		UserID:  p.UserID,
+		Status:  StatusRefunded,
+		Reason:  reason,
+	})
+	if err != nil {
+		return Payment{}, err
+	}
+	if err := s.outboxRepo.Insert(ctx, tx, outID, payload); err != nil {
+		return Payment{}, err
+	}
+	p.Status, p.Reason = StatusRefunded, reason
+	return p, nil
+}
+
+func statusReasonCode(status string) string {
+	if status == account.StatusClosed {
+		return ReasonAccountClosed
+	}
+	return ReasonAccountFrozen
+}
@@ -0,0 +1,287 @@
+package payment_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/example/webshop/payments/internal/account"
+	"github.com/example/webshop/payments/internal/db"
+	"github.com/example/webshop/payments/internal/inbox"
+	"github.com/example/webshop/payments/internal/outbox"
+	"github.com/example/webshop/payments/internal/payment"
+	"github.com/example/webshop/payments/internal/risk"
+)
+
+// Тесты гоняют ProcessPayment по живой базе: TEST_DATABASE_URL=postgres://... go test ./internal/payment/
+// Каждый прогон работает со своими user_id/order_id, так что базу можно не чистить.
+
+var nextOrderID atomic.Int64
+
+// order_id в payments — INT, как orders.id: старт случайный в верхней половине int4,
+// с запасом в 2^29 заказов на прогон, чтобы повторные прогоны по одной базе не пересекались
+func init() {
+	nextOrderID.Store(1<<30 + rand.Int63n(1<<29))
+}
+
+type harness struct {
+	db       *sql.DB
+	svc      *payment.Service
+	accounts *account.Repository
+	prefix   string
+}
+
+func newHarness(t testing.TB) *harness {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	conn, err := sql.Open("pgx", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(32)
+	t.Cleanup(func() { conn.Close() })
+	if err := db.Migrate(context.Background(), conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	accounts := account.NewRepository(conn)
+	riskRepo := risk.NewRepository(conn)
+	svc := payment.NewService(conn, accounts, payment.NewRepository(conn), inbox.NewRepository(conn),
+		outbox.NewRepository(conn), risk.NewEngine(riskRepo), riskRepo, payment.TxTimeouts{Statement: 5 * time.Second, Lock: 2 * time.Second})
+	return &harness{db: conn, svc: svc, accounts: accounts, prefix: "stress-" + uuid.NewString()[:8] + "-"}
+}
+
+func (h *harness) seedAccounts(t testing.TB, n int, balance int64) map[string]int64 {
+	t.Helper()
+	ctx := context.Background()
+	initial := make(map[string]int64, n)
+	for i := 0; i < n; i++ {
+		userID := fmt.Sprintf("%s%d", h.prefix, i)
+		if _, err := h.accounts.CreateIfAbsent(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := h.accounts.Credit(ctx, h.db, userID, balance); err != nil {
+			t.Fatal(err)
+		}
+		initial[userID] = balance
+	}
+	return initial
+}
+
+// deliver прогоняет задачи параллельно. Ошибка (например, конфликт по уникальному order_id
+// у двух разных сообщений одного заказа) — это nack с повторной доставкой, как в консьюмере.
+func (h *harness) deliver(t testing.TB, tasks []payment.PaymentTask, workers int) {
+	t.Helper()
+	ch := make(chan payment.PaymentTask)
+	var wg sync.WaitGroup
+	var retries, failed atomic.Int64
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range ch {
+				var err error
+				for attempt := 0; attempt < 20; attempt++ {
+					if err = h.svc.ProcessPayment(context.Background(), task); err == nil {
+						break
+					}
+					retries.Add(1)
+					time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
+				}
+				if err != nil {
+					failed.Add(1)
+					t.Errorf("order %d msg %s: %v", task.OrderID, task.MessageID, err)
+				}
+			}
+		}()
+	}
+	for _, task := range tasks {
+		ch <- task
+	}
+	close(ch)
+	wg.Wait()
+	t.Logf("delivered %d tasks, %d retries, %d failed", len(tasks), retries.Load(), failed.Load())
+}
+
+// check сверяет инварианты по всем счетам прогона:
+// баланс не уходит в минус, на заказ не больше одного платежа и одного события,
+// каждый заказ обработан, сумма списаний равна изменению баланса.
+func (h *harness) check(t testing.TB, initial map[string]int64, orders map[int64]int64) {
+	t.Helper()
+	ctx := context.Background()
+	like := h.prefix + "%"
+
+	rows, err := h.db.QueryContext(ctx, `SELECT user_id, balance FROM accounts WHERE user_id LIKE $1`, like)
+	if err != nil {
+		t.Fatal(err)
+	}
+	final := map[string]int64{}
+	for rows.Next() {
+		var userID string
+		var balance int64
+		if err := rows.Scan(&userID, &balance); err != nil {
+			t.Fatal(err)
+		}
+		final[userID] = balance
+		if balance < 0 {
+			t.Errorf("user %s: negative balance %d", userID, balance)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	rows, err = h.db.QueryContext(ctx, `
+		SELECT order_id, user_id, amount, status FROM payments WHERE user_id LIKE $1
+	`, like)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int64]bool{}
+	charged := map[string]int64{}
+	for rows.Next() {
+		var orderID, amount int64
+		var userID, status string
+		if err := rows.Scan(&orderID, &userID, &amount, &status); err != nil {
+			t.Fatal(err)
+		}
+		if seen[orderID] {
+			t.Errorf("order %d: charged more than once", orderID)
+		}
+		seen[orderID] = true
+		if want, ok := orders[orderID]; !ok || want != amount {
+			t.Errorf("order %d: unexpected payment amount %d", orderID, amount)
+		}
+		if status == payment.StatusFinished {
+			charged[userID] += amount
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if len(seen) != len(orders) {
+		t.Errorf("processed %d orders, want %d", len(seen), len(orders))
+	}
+
+	for userID, start := range initial {
+		if diff := start - final[userID]; diff != charged[userID] {
+			t.Errorf("user %s: balance dropped by %d, payments sum %d", userID, diff, charged[userID])
+		}
+	}
+
+	var dupEvents int
+	err = h.db.QueryRowContext(ctx, `
+		SELECT count(*) FROM (
+			SELECT payload->'data'->>'order_id' FROM outbox
+			WHERE payload->'data'->>'user_id' LIKE $1
+			GROUP BY 1 HAVING count(*) > 1
+		) d
+	`, like).Scan(&dupEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dupEvents > 0 {
+		t.Errorf("%d orders got more than one payment event", dupEvents)
+	}
+}
+
+func TestProcessPaymentStress(t *testing.T) {
+	h := newHarness(t)
+
+	accounts, orderCount := 8, 2000
+	if testing.Short() {
+		orderCount = 200
+	}
+	initial := h.seedAccounts(t, accounts, 10000)
+	users := make([]string, 0, len(initial))
+	for userID := range initial {
+		users = append(users, userID)
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	orders := map[int64]int64{}
+	var tasks []payment.PaymentTask
+	for i := 0; i < orderCount; i++ {
+		task := payment.PaymentTask{
+			MessageID: uuid.NewString(),
+			OrderID:   nextOrderID.Add(1),
+			UserID:    users[rnd.Intn(len(users))],
+			Amount:    int64(1 + rnd.Intn(300)),
+		}
+		orders[task.OrderID] = task.Amount
+		// повторная доставка того же сообщения
+		for n := 1 + rnd.Intn(3); n > 0; n-- {
+			tasks = append(tasks, task)
+		}
+		// тот же заказ, но другим сообщением (переотправка из orders)
+		if rnd.Intn(4) == 0 {
+			dup := task
+			dup.MessageID = uuid.NewString()
+			tasks = append(tasks, dup)
+		}
+	}
+	rnd.Shuffle(len(tasks), func(i, j int) { tasks[i], tasks[j] = tasks[j], tasks[i] })
+
+	h.deliver(t, tasks, 32)
+	h.check(t, initial, orders)
+}
+
+// FuzzProcessPayment: каждый байт входа — одна доставка для одного из восьми заказов
+// одного счёта. Младшие 3 бита — заказ, остальные решают, переиспользовать ли message_id.
+// Порядок байт задаёт порядок, а доставка идёт параллельно.
+func FuzzProcessPayment(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 1, 2})
+	f.Add([]byte{0, 8, 16, 24, 0, 8})
+	f.Add([]byte{7, 6, 5, 4, 3, 2, 1, 0, 0, 1, 2, 3})
+	f.Add([]byte{1, 9, 17, 25, 33, 41, 49, 57, 255, 254})
+
+	h := newHarness(f)
+	f.Fuzz(func(t *testing.T, schedule []byte) {
+		if len(schedule) > 64 {
+			schedule = schedule[:64]
+		}
+		run := &harness{db: h.db, svc: h.svc, accounts: h.accounts, prefix: h.prefix + uuid.NewString()[:8] + "-"}
+		initial := run.seedAccounts(t, 1, 500)
+		var userID string
+		for id := range initial {
+			userID = id
+		}
+
+		var base [8]payment.PaymentTask
+		for i := range base {
+			base[i] = payment.PaymentTask{
+				MessageID: uuid.NewString(),
+				OrderID:   nextOrderID.Add(1),
+				UserID:    userID,
+				Amount:    int64(50 + 40*i),
+			}
+		}
+		orders := map[int64]int64{}
+		tasks := make([]payment.PaymentTask, 0, len(schedule))
+		for _, b := range schedule {
+			task := base[b&7]
+			if b>>3&3 == 3 {
+				task.MessageID = uuid.NewString()
+			}
+			orders[task.OrderID] = task.Amount
+			tasks = append(tasks, task)
+		}
+
+		run.deliver(t, tasks, len(tasks)+1)
+		run.check(t, initial, orders)
+	})
+}
@@ -0,0 +1,156 @@
+package risk
+
+import (
+	"context"
+	"time"
+
+	"github.com/example/webshop/payments/internal/account"
+)
+
+// Коды отказов по лимитам — уходят в PaymentResult.reason_code
+const (
+	CodeSinglePayment = "LIMIT_SINGLE_PAYMENT"
+	CodeDailySpend    = "LIMIT_DAILY_SPEND"
+	CodeMonthlySpend  = "LIMIT_MONTHLY_SPEND"
+	CodeOrdersPerHour = "LIMIT_ORDERS_PER_HOUR"
+)
+
+// Коды флагов — платёж проходит, но попадает на ручную проверку
+const (
+	FlagLargeAmount     = "REVIEW_LARGE_AMOUNT"
+	FlagNewAccountDrain = "REVIEW_NEW_ACCOUNT_DRAIN"
+)
+
+// newAccountAge — сколько счёт считается "свежим" для fraud-правил
+const newAccountAge = 24 * time.Hour
+
+type Verdict int
+
+const (
+	Pass Verdict = iota
+	Flag
+	Reject
+)
+
+type Decision struct {
+	Verdict Verdict
+	Code    string
+	Reason  string
+}
+
+type Input struct {
+	OrderID int64
+	Amount  int64
+	Account account.Account
+	Limits  Limits
+}
+
+// Rule смотрит на платёж внутри транзакции оплаты (счёт уже под FOR UPDATE)
+type Rule func(ctx context.Context, tx DBTX, in Input) (Decision, error)
+
+// Result — итог прогона правил: RejectCode пустой, если платёж можно проводить
+type Result struct {
+	RejectCode   string
+	RejectReason string
+	Flags        []string
+}
+
+type Engine struct {
+	repo  *Repository
+	rules []Rule
+}
+
+func NewEngine(repo *Repository) *Engine {
+	e := &Engine{repo: repo}
+	e.rules = []Rule{
+		maxSinglePayment,
+		e.spendLimit("day", CodeDailySpend, "daily spend limit exceeded", func(l Limits) int64 { return l.DailyLimit }),
+		e.spendLimit("month", CodeMonthlySpend, "monthly spend limit exceeded", func(l Limits) int64 { return l.MonthlyLimit }),
+		e.ordersPerHour,
+		largeAmount,
+		newAccountDrain,
+	}
+	return e
+}
+
+// Evaluate гоняет правила по порядку; первый Reject останавливает прогон
+func (e *Engine) Evaluate(ctx context.Context, tx DBTX, orderID, amount int64, acc account.Account) (Result, error) {
+	limits, err := e.repo.LimitsTx(ctx, tx, acc.UserID)
+	if err != nil {
+		return Result{}, err
+	}
+	in := Input{OrderID: orderID, Amount: amount, Account: acc, Limits: limits}
+
+	var res Result
+	for _, rule := range e.rules {
+		d, err := rule(ctx, tx, in)
+		if err != nil {
+			return Result{}, err
+		}
+		switch d.Verdict {
+		case Reject:
+			res.RejectCode = d.Code
+			res.RejectReason = d.Reason
+			return res, nil
+		case Flag:
+			res.Flags = append(res.Flags, d.Code)
+		}
+	}
+	return res, nil
+}
+
+func maxSinglePayment(_ context.Context, _ DBTX, in Input) (Decision, error) {
+	if in.Limits.MaxSinglePayment > 0 && in.Amount > in.Limits.MaxSinglePayment {
+		return Decision{Verdict: Reject, Code: CodeSinglePayment, Reason: "single payment limit exceeded"}, nil
+	}
+	return Decision{}, nil
+}
+
+func (e *Engine) spendLimit(period, code, reason string, limit func(Limits) int64) Rule {
+	return func(ctx context.Context, tx DBTX, in Input) (Decision, error) {
+		ceiling := limit(in.Limits)
+		if ceiling <= 0 {
+			return Decision{}, nil
+		}
+		spent, err := e.repo.SpentSince(ctx, tx, in.Account.UserID, period)
+		if err != nil {
+			return Decision{}, err
+		}
+		if spent+in.Amount > ceiling {
+			return Decision{Verdict: Reject, Code: code, Reason: reason}, nil
+		}
+		return Decision{}, nil
+	}
+}
+
+func (e *Engine) ordersPerHour(ctx context.Context, tx DBTX, in Input) (Decision, error) {
+	if in.Limits.MaxOrdersPerHour <= 0 {
+		return Decision{}, nil
+	}
+	n, err := e.repo.PaymentsLastHour(ctx, tx, in.Account.UserID)
+	if err != nil {
+		return Decision{}, err
+	}
+	if n >= in.Limits.MaxOrdersPerHour {
+		return Decision{Verdict: Reject, Code: CodeOrdersPerHour, Reason: "too many orders per hour"}, nil
+	}
+	return Decision{}, nil
+}
+
+func largeAmount(_ context.Context, _ DBTX, in Input) (Decision, error) {
+	if in.Limits.ReviewAmount > 0 && in.Amount >= in.Limits.ReviewAmount {
+		return Decision{Verdict: Flag, Code: FlagLargeAmount}, nil
+	}
+	return Decision{}, nil
+}
+
+// newAccountDrain — свежий счёт сразу выгребает почти весь баланс (пополнил и слил)
+func newAccountDrain(_ context.Context, _ DBTX, in Input) (Decision, error) {
+	if time.Since(in.Account.CreatedAt) > newAccountAge || in.Account.Balance <= 0 {
+		return Decision{}, nil
+	}
+	if in.Amount*10 >= in.Account.Balance*9 {
+		return Decision{Verdict: Flag, Code: FlagNewAccountDrain}, nil
+	}
+	return Decision{}, nil
+}
@@ -0,0 +1,150 @@
+package risk
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// DBTX — общий мини-интерфейс под DB или транзакцию
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+const (
+	ReviewPending  = "PENDING"
+	ReviewApproved = "APPROVED"
+	ReviewRejected = "REJECTED"
+)
+
+// Limits — лимиты счёта; 0 значит "без ограничения"
+type Limits struct {
+	UserID           string `json:"user_id"`
+	MaxSinglePayment int64  `json:"max_single_payment"`
+	DailyLimit       int64  `json:"daily_limit"`
+	MonthlyLimit     int64  `json:"monthly_limit"`
+	MaxOrdersPerHour int64  `json:"max_orders_per_hour"`
+	ReviewAmount     int64  `json:"review_amount"`
+}
+
+type Review struct {
+	OrderID    int64      `json:"order_id"`
+	UserID     string     `json:"user_id"`
+	Amount     int64      `json:"amount"`
+	Codes      []string   `json:"codes"`
+	Status     string     `json:"status"`
+	Note       string     `json:"note,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Limits(ctx context.Context, userID string) (Limits, error) {
+	return r.LimitsTx(ctx, r.db, userID)
+}
+
+// LimitsTx отдаёт нулевые лимиты, если для счёта ничего не настроено
+func (r *Repository) LimitsTx(ctx context.Context, tx DBTX, userID string) (Limits, error) {
+	l := Limits{UserID: userID}
+	err := tx.QueryRowContext(ctx, `
+		SELECT max_single_payment, daily_limit, monthly_limit, max_orders_per_hour, review_amount
+		FROM account_limits WHERE user_id=$1
+	`, userID).Scan(&l.MaxSinglePayment, &l.DailyLimit, &l.MonthlyLimit, &l.MaxOrdersPerHour, &l.ReviewAmount)
+	if err == sql.ErrNoRows {
+		return l, nil
+	}
+	return l, err
+}
+
+func (r *Repository) SaveLimits(ctx context.Context, l Limits) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO account_limits(user_id, max_single_payment, daily_limit, monthly_limit, max_orders_per_hour, review_amount)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			max_single_payment = EXCLUDED.max_single_payment,
+			daily_limit = EXCLUDED.daily_limit,
+			monthly_limit = EXCLUDED.monthly_limit,
+			max_orders_per_hour = EXCLUDED.max_orders_per_hour,
+			review_amount = EXCLUDED.review_amount,
+			updated_at = now()
+	`, l.UserID, l.MaxSinglePayment, l.DailyLimit, l.MonthlyLimit, l.MaxOrdersPerHour, l.ReviewAmount)
+	return err
+}
+
+// SpentSince — сумма успешных списаний с начала периода (day/month)
+func (r *Repository) SpentSince(ctx context.Context, tx DBTX, userID, period string) (int64, error) {
+	var total int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM payments
+		WHERE user_id=$1 AND status='FINISHED' AND created_at >= date_trunc($2, now())
+	`, userID, period).Scan(&total)
+	return total, err
+}
+
+// PaymentsLastHour считает все попытки оплаты, включая отменённые
+func (r *Repository) PaymentsLastHour(ctx context.Context, tx DBTX, userID string) (int64, error) {
+	var n int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM payments
+		WHERE user_id=$1 AND created_at >= now() - interval '1 hour'
+	`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *Repository) InsertReview(ctx context.Context, tx DBTX, orderID int64, userID string, amount int64, codes []string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO payment_reviews(order_id, user_id, amount, codes, status)
+		VALUES ($1,$2,$3,$4,$5)
+		ON CONFLICT DO NOTHING
+	`, orderID, userID, amount, strings.Join(codes, ","), ReviewPending)
+	return err
+}
+
+func (r *Repository) ListReviews(ctx context.Context, status string) ([]Review, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT order_id, user_id, amount, codes, status, COALESCE(note, ''), created_at, resolved_at
+		FROM payment_reviews
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []Review
+	for rows.Next() {
+		var rv Review
+		var codes string
+		if err := rows.Scan(&rv.OrderID, &rv.UserID, &rv.Amount, &codes, &rv.Status, &rv.Note, &rv.CreatedAt, &rv.ResolvedAt); err != nil {
+			return nil, err
+		}
+		rv.Codes = strings.Split(codes, ",")
+		res = append(res, rv)
+	}
+	return res, rows.Err()
+}
+
+// ResolveReview закрывает только ожидающую проверку; false — нечего закрывать
+func (r *Repository) ResolveReview(ctx context.Context, tx DBTX, orderID int64, status, note string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE payment_reviews
+		SET status = $1, note = $2, resolved_at = now()
+		WHERE order_id = $3 AND status = $4
+	`, status, note, orderID, ReviewPending)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}